@@ -5,8 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/story"
 	"github.com/fxnn/news/internal/version"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -195,3 +197,401 @@ func TestExtractorCmd_PrecedenceOrder(t *testing.T) {
 
 	assert.Equal(t, "flag", capturedCfg.Maildir)
 }
+
+func TestExtractorCmd_BodyPreference(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s", "--body-preference", "HTML-First"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "html-first", capturedCfg.BodyPreference)
+}
+
+func TestExtractorCmd_RejectsUnknownBodyPreference(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s", "--body-preference", "shortest"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown body preference")
+}
+
+func TestExtractorCmd_RejectsUnknownProvider(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+	t.Setenv("STORY_EXTRACTOR_LLM_PROVIDER", "gemini")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown llm.provider")
+}
+
+func TestExtractorCmd_Concurrency(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s", "--concurrency", "8"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 8, capturedCfg.Concurrency)
+}
+
+func TestExtractorCmd_RejectsZeroConcurrency(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s", "--concurrency", "0"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "concurrency")
+}
+
+func TestExtractorCmd_Reprocess(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"reprocess", "--maildir", "/m", "--storydir", "/s", "--since", "2024-03-01", "--zero-stories"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	require.NotNil(t, capturedCfg)
+	assert.Equal(t, "/m", capturedCfg.Maildir)
+}
+
+func TestExtractorCmd_ReprocessRequiresSelector(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"reprocess", "--maildir", "/m", "--storydir", "/s"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no emails selected")
+}
+
+func TestExtractorCmd_ReprocessRejectsInvalidDate(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"reprocess", "--maildir", "/m", "--storydir", "/s", "--until", "03/01/2024"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --until")
+}
+
+func TestExtractorCmd_PreviewNeedsNoDirs(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	called := false
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		called = true
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--preview", "message.eml"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestExtractorCmd_DryRunRequiresMaildir(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--dry-run", "--storydir", "/s"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "maildir is required")
+}
+
+func TestExtractorCmd_RejectsDryRunWithPreview(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--dry-run", "--preview", "message.eml"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be combined")
+}
+
+func TestExtractorCmd_StdoutWithoutStorydir(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--ledger", "/l/ledger.jsonl"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	require.NotNil(t, capturedCfg)
+	assert.Empty(t, capturedCfg.Storydir)
+	assert.Equal(t, "/l/ledger.jsonl", capturedCfg.Ledger)
+}
+
+func TestExtractorCmd_DirOutputRequiresStorydir(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--output", "both"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storydir is required")
+}
+
+func TestExtractorCmd_ReprocessRequiresStorydir(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"reprocess", "--maildir", "/m", "--all"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storydir is required")
+}
+
+func TestExtractorCmd_BudgetLimits(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+	t.Setenv("STORY_EXTRACTOR_MAX_COST_PER_RUN", "0.5")
+	t.Setenv("STORY_EXTRACTOR_MAX_TOKENS_PER_DAY", "1000000")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, capturedCfg.MaxCostPerRun, 1e-9)
+	assert.Equal(t, 1000000, capturedCfg.MaxTokensPerDay)
+}
+
+func TestExtractorCmd_RejectsCostLimitWithoutPrice(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m", "--storydir", "/s"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+	t.Setenv("STORY_EXTRACTOR_LLM_MODEL", "my-finetune")
+	t.Setenv("STORY_EXTRACTOR_MAX_COST_PER_RUN", "1")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "needs the price")
+}
+
+func TestExtractorCmd_RejectsDailyLimitWithoutState(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"--maildir", "/m"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+	t.Setenv("STORY_EXTRACTOR_MAX_TOKENS_PER_DAY", "1000000")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_tokens_per_day needs budget_state")
+
+	t.Setenv("STORY_EXTRACTOR_BUDGET_STATE", filepath.Join(t.TempDir(), "budget.json"))
+	require.NoError(t, cmd.Execute())
+}
+
+func TestExtractorCmd_CacheFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     string
+		args        []string
+		wantEnabled bool
+		wantRefresh bool
+	}{
+		{"default", "", nil, false, false},
+		{"enabled", "true", nil, true, false},
+		{"no cache", "true", []string{"--no-cache"}, false, false},
+		{"refresh cache", "true", []string{"--refresh-cache"}, true, true},
+		{"dry run", "true", []string{"--dry-run"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			config.SetupStoryExtractor(v)
+
+			var capturedCfg *config.StoryExtractor
+			cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+				capturedCfg = cfg
+				return nil
+			})
+
+			cmd.SetArgs(append([]string{"--maildir", "/m", "--storydir", "/s"}, tt.args...))
+			t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+			if tt.enabled != "" {
+				t.Setenv("STORY_EXTRACTOR_LLM_CACHE_ENABLED", tt.enabled)
+			}
+
+			err := cmd.Execute()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEnabled, capturedCfg.LLM.Cache.Enabled)
+			assert.Equal(t, tt.wantRefresh, capturedCfg.LLM.Cache.Refresh)
+			assert.Equal(t, 100, capturedCfg.LLM.Cache.MaxSizeMB)
+		})
+	}
+}
+
+func TestExtractorCmd_EvalReplayNeedsNoAPIKey(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+
+	var capturedCfg *config.StoryExtractor
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		capturedCfg = cfg
+		return nil
+	})
+
+	cmd.SetArgs([]string{"eval", "--replay"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "")
+
+	err := cmd.Execute()
+	require.NoError(t, err)
+	require.NotNil(t, capturedCfg)
+	assert.False(t, capturedCfg.URLs.ResolveRedirects)
+}
+
+func TestExtractorCmd_EvalRejectsRecordWithReplay(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"eval", "--record", "--replay"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "dummy-key")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be combined")
+}
+
+func TestExtractorCmd_MigrateRequiresStorydir(t *testing.T) {
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, func(cfg *config.StoryExtractor) error {
+		return nil
+	})
+
+	cmd.SetArgs([]string{"migrate"})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "")
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storydir is required")
+}
+
+func TestExtractorCmd_MigrateDirs(t *testing.T) {
+	storydir := t.TempDir()
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	old := []story.Story{{Headline: "Old", Teaser: "Video. A recording.", URL: "https://example.com/1", Date: date}}
+	require.NoError(t, story.WriteStoriesToDir(storydir, "<test@example.com>", date, old))
+
+	v := viper.New()
+	config.SetupStoryExtractor(v)
+	cmd := NewStoryExtractorCmd(v, nil)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"migrate", storydir})
+	t.Setenv("STORY_EXTRACTOR_LLM_API_KEY", "")
+
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "migrated 1 stories")
+
+	migrated, err := story.ReadStoriesFromDir(storydir, "<test@example.com>", date)
+	require.NoError(t, err)
+	require.Len(t, migrated, 1)
+	assert.Equal(t, story.ContentVideo, migrated[0].ContentType)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/extractor"
+	"github.com/fxnn/news/internal/llm"
+)
+
+// writeEstimate reports what a dry run would send to the LLM, and what it
+// would cost with the configured model and the other models of known price:
+// the prompt cost, and the maximum cost if every reply reached the output
+// token limit.
+func writeEstimate(w io.Writer, cfg *config.LLM, result *extractor.Result) error {
+	est := result.Estimate
+	var b strings.Builder
+	fmt.Fprintf(&b, "Emails:            %d to process, %d skipped, %d failed to parse\n", result.Processed, result.Skipped, result.Errors)
+	fmt.Fprintf(&b, "Requests:          %d\n", est.Requests)
+	fmt.Fprintf(&b, "Prompt tokens:     ~%d\n", est.PromptTokens)
+	fmt.Fprintf(&b, "Max output tokens: %d\n\n", est.MaxOutputTokens)
+
+	type row struct {
+		model string
+		price llm.Price
+	}
+	var rows []row
+	configured := strings.ToLower(cfg.Model)
+	for name, price := range llm.Prices(cfg) {
+		if name != configured {
+			rows = append(rows, row{name, price})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		ci, cj := rows[i].price.Cost(est.PromptTokens, 0), rows[j].price.Cost(est.PromptTokens, 0)
+		if ci != cj {
+			return ci < cj
+		}
+		return rows[i].model < rows[j].model
+	})
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tPROMPT COST\tMAX COST")
+	if price, ok := llm.PriceOf(cfg); ok {
+		fmt.Fprintf(tw, "%s (configured)\t$%.4f\t$%.4f\n", cfg.Model,
+			price.Cost(est.PromptTokens, 0), price.Cost(est.PromptTokens, est.MaxOutputTokens))
+	} else {
+		fmt.Fprintf(tw, "%s (configured)\tunknown\tunknown\n", cfg.Model)
+	}
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t$%.4f\t$%.4f\n", r.model,
+			r.price.Cost(est.PromptTokens, 0), r.price.Cost(est.PromptTokens, est.MaxOutputTokens))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/eval"
+	"github.com/fxnn/news/internal/extractor"
+	"github.com/fxnn/news/internal/llm"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultFixtures is the fixture directory in the repository, relative to its root.
+const defaultFixtures = "internal/eval/testdata"
+
+func newEvalCmd(v *viper.Viper, cfgFile *string, runFn RunExtractorFunc) *cobra.Command {
+	var (
+		fixtures, recordings string
+		record, replay       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Measure the extraction quality on fixture emails",
+		Long: `Extract the stories of fixture emails with known stories, and report the
+precision and recall of the story URLs, and how many boilerplate and
+sponsored links leaked into the stories.
+
+A fixture is an email <name>.eml next to <name>.expected.json. With --record,
+the model's replies are stored in the recordings directory; --replay uses
+them instead of calling the model, which needs no API key. Replays fail once
+the prompt changed; record again to evaluate the new prompt.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if record && replay {
+				return fmt.Errorf("--record and --replay cannot be combined")
+			}
+			if recordings == "" {
+				recordings = filepath.Join(fixtures, "recordings")
+			}
+
+			load := loadConfig
+			if replay {
+				load = loadSettings
+			}
+			cfg, err := load(v, *cfgFile)
+			if err != nil {
+				return err
+			}
+			// Expectations must not depend on the network
+			cfg.URLs.ResolveRedirects = false
+
+			// Execute injected run function (for testing) or default logic
+			if runFn != nil {
+				return runFn(cfg)
+			}
+
+			fx, err := eval.LoadFixtures(fixtures)
+			if err != nil {
+				return err
+			}
+
+			log := logger.New(cfg.Verbose)
+			storyExtractor, err := newEvalExtractor(cfg, recordings, record, replay)
+			if err != nil {
+				return err
+			}
+			if !replay {
+				storyExtractor = llm.NewRetryingExtractor(storyExtractor, cfg.LLM.Retry, log)
+			}
+			processor := extractor.NewProcessor(cfg, log, storyExtractor)
+
+			report := eval.Run(fx, processor.ExtractFile)
+			if err := report.Write(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			if n := report.Errors(); n > 0 {
+				return fmt.Errorf("%d of %d fixtures failed", n, len(fx))
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&fixtures, "fixtures", defaultFixtures, "Directory of fixture emails and their expected stories")
+	f.StringVar(&recordings, "recordings", "", "Directory of recorded model replies (default: recordings in the fixtures directory)")
+	f.BoolVar(&record, "record", false, "Call the model and record its replies")
+	f.BoolVar(&replay, "replay", false, "Replay recorded replies instead of calling the model")
+
+	return cmd
+}
+
+// newEvalExtractor creates the extractor for an evaluation. The response
+// cache is never used, so that the current prompt is evaluated.
+func newEvalExtractor(cfg *config.StoryExtractor, recordings string, record, replay bool) (story.Extractor, error) {
+	switch {
+	case replay:
+		return llm.NewReplayExtractor(&cfg.LLM, recordings)
+	case record:
+		return llm.NewRecordingExtractor(&cfg.LLM, recordings)
+	default:
+		return llm.NewExtractor(&cfg.LLM)
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/fxnn/news/internal/budget"
 	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
 	"github.com/fxnn/news/internal/extractor"
 	"github.com/fxnn/news/internal/llm"
 	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
 	"github.com/fxnn/news/internal/version"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -21,33 +26,47 @@ func main() {
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, budget.ErrExceeded) {
+			os.Exit(exitBudgetExceeded)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitBudgetExceeded is the exit code of runs stopped by a budget limit, so
+// that cron wrappers can tell them from failures.
+const exitBudgetExceeded = 3
+
 type RunExtractorFunc func(cfg *config.StoryExtractor) error
 
 func NewStoryExtractorCmd(v *viper.Viper, runFn RunExtractorFunc) *cobra.Command {
-	var cfgFile string
+	var (
+		cfgFile     string
+		dryRun      bool
+		previewFile string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "story-extractor",
 		Short: "Extract stories from emails",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.LoadStoryExtractor(v, cfgFile)
-			if err != nil {
-				return err
+			if dryRun && previewFile != "" {
+				return fmt.Errorf("--dry-run and --preview cannot be combined")
 			}
 
-			// Validate required
-			if cfg.Maildir == "" {
-				return fmt.Errorf("maildir is required (via flag, config, or env)")
+			cfg, err := loadConfig(v, cfgFile)
+			if err != nil {
+				return err
 			}
-			if cfg.Storydir == "" {
-				return fmt.Errorf("storydir is required")
+			// Preview reads a single email file and writes to stdout only
+			if previewFile == "" {
+				if err := requireDirs(cfg); err != nil {
+					return err
+				}
 			}
-			if cfg.LLM.APIKey == "" {
-				return fmt.Errorf("llm.api_key is required (via config or STORY_EXTRACTOR_LLM_API_KEY env var)")
+			if dryRun {
+				// The dry run calls no LLM, so it has no replies to cache
+				cfg.LLM.Cache.Enabled = false
 			}
 
 			// Execute injected run function (for testing) or default logic
@@ -55,48 +74,177 @@ func NewStoryExtractorCmd(v *viper.Viper, runFn RunExtractorFunc) *cobra.Command
 				return runFn(cfg)
 			}
 
-			// Initialize dependencies
 			log := logger.New(cfg.Verbose)
-			log.Info("starting story extractor", "maildir", cfg.Maildir, "storydir", cfg.Storydir)
-
-			storyExtractor := llm.NewOpenAIExtractor(&cfg.LLM)
-
-			processor := extractor.NewProcessor(cfg, log, storyExtractor)
-			result, err := processor.Run()
+			processor, err := newProcessor(cfg, log)
 			if err != nil {
-				log.Error("processing failed", "error", err)
 				return err
 			}
 
-			if result.Errors > 0 {
-				return fmt.Errorf("processing completed with %d errors", result.Errors)
+			switch {
+			case previewFile != "":
+				return processor.Preview(previewFile, cmd.OutOrStdout())
+			case dryRun:
+				result, err := processor.DryRun()
+				if err != nil {
+					return err
+				}
+				return writeEstimate(cmd.OutOrStdout(), &cfg.LLM, result)
 			}
 
-			return nil
+			result, err := processor.Run()
+			return checkResult(log, result, err)
 		},
 	}
 
-	f := cmd.Flags()
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report the emails, tokens and cost a run would take, without calling the LLM or writing files")
+	cmd.Flags().StringVar(&previewFile, "preview", "", "Extract the stories of this email file and print them, without writing files")
+
+	// Flags are persistent, so that subcommands like reprocess share them
+	f := cmd.PersistentFlags()
 	f.StringVar(&cfgFile, "config", "", "config file (default: ./story-extractor.toml or $HOME/story-extractor.toml)")
 	f.String("maildir", "", "Path to the Maildir directory")
-	f.String("storydir", "", "Output directory for story files")
+	f.String("storydir", "", "Output directory for story files; stories go to stdout without one")
+	f.String("output", "", "Where stories go: dir, stdout (JSON Lines) or both (default: dir with a storydir, stdout otherwise)")
+	f.String("ledger", "", "Ledger file of processed emails (default: .ledger.jsonl in the storydir, none for stdout output)")
 	f.Int("limit", 0, "Limit number of emails to process")
 	f.Bool("verbose", false, "Enable verbose output")
 	f.Bool("log-headers", false, "Log email headers")
 	f.Bool("log-bodies", false, "Log email bodies")
 	f.Bool("log-stories", false, "Log extracted stories")
+	f.String("body-preference", "plain-first", "Email body to extract from: plain-first, html-first, longest or both")
+	f.String("url-validation", "drop", "Stories whose URL is not in the email: drop, flag or off")
+	f.Int("concurrency", 1, "Number of emails to process in parallel")
+	f.Bool("no-cache", false, "Neither read nor write the LLM response cache")
+	f.Bool("refresh-cache", false, "Call the LLM even for cached emails, and cache the new responses")
 
 	// BindPFlag should never fail (only fails if flag doesn't exist, which is a programming error)
 	// but if it does, exit cleanly rather than panic
 	cobra.CheckErr(v.BindPFlag("maildir", f.Lookup("maildir")))
 	cobra.CheckErr(v.BindPFlag("storydir", f.Lookup("storydir")))
+	cobra.CheckErr(v.BindPFlag("output", f.Lookup("output")))
+	cobra.CheckErr(v.BindPFlag("ledger", f.Lookup("ledger")))
 	cobra.CheckErr(v.BindPFlag("limit", f.Lookup("limit")))
 	cobra.CheckErr(v.BindPFlag("verbose", f.Lookup("verbose")))
 	cobra.CheckErr(v.BindPFlag("log_headers", f.Lookup("log-headers")))
 	cobra.CheckErr(v.BindPFlag("log_bodies", f.Lookup("log-bodies")))
 	cobra.CheckErr(v.BindPFlag("log_stories", f.Lookup("log-stories")))
+	cobra.CheckErr(v.BindPFlag("body_preference", f.Lookup("body-preference")))
+	cobra.CheckErr(v.BindPFlag("url_validation", f.Lookup("url-validation")))
+	cobra.CheckErr(v.BindPFlag("concurrency", f.Lookup("concurrency")))
+	cobra.CheckErr(v.BindPFlag("no_cache", f.Lookup("no-cache")))
+	cobra.CheckErr(v.BindPFlag("llm.cache.refresh", f.Lookup("refresh-cache")))
 
 	cmd.AddCommand(version.NewCommand())
+	cmd.AddCommand(newReprocessCmd(v, &cfgFile, runFn))
+	cmd.AddCommand(newEvalCmd(v, &cfgFile, runFn))
+	cmd.AddCommand(newMigrateCmd(v, &cfgFile, runFn))
 
 	return cmd
 }
+
+// loadConfig loads and validates the configuration, except for the
+// directories; see requireDirs.
+func loadConfig(v *viper.Viper, cfgFile string) (*config.StoryExtractor, error) {
+	cfg, err := loadSettings(v, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := llm.CheckConfig(&cfg.LLM); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadSettings loads and validates the configuration like loadConfig, but
+// leaves the LLM provider settings to be checked by the caller.
+func loadSettings(v *viper.Viper, cfgFile string) (*config.StoryExtractor, error) {
+	cfg, err := config.LoadStoryExtractor(v, cfgFile)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyPreference, err := email.ParseBodyPreference(cfg.BodyPreference)
+	if err != nil {
+		return nil, err
+	}
+	cfg.BodyPreference = string(bodyPreference)
+	if cfg.URLValidation, err = extractor.ParseURLValidation(cfg.URLValidation); err != nil {
+		return nil, err
+	}
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if cfg.MaxCostPerRun < 0 || cfg.MaxTokensPerDay < 0 {
+		return nil, fmt.Errorf("max_cost_per_run and max_tokens_per_day must not be negative")
+	}
+	if _, ok := llm.PriceOf(&cfg.LLM); cfg.MaxCostPerRun > 0 && !ok {
+		return nil, fmt.Errorf("max_cost_per_run needs the price of model %q: set input_price and output_price in [[llm.models]]", cfg.LLM.Model)
+	}
+	if cfg.MaxTokensPerDay > 0 && cfg.BudgetState == "" && cfg.Storydir == "" {
+		// Kept in memory only, the daily spend would reset on every run
+		return nil, fmt.Errorf("max_tokens_per_day needs budget_state or a storydir to keep the daily spend across runs")
+	}
+	if v.GetBool("no_cache") {
+		cfg.LLM.Cache.Enabled = false
+	}
+	if cfg.LLM.Cache.MaxSizeMB < 0 {
+		return nil, fmt.Errorf("llm.cache.max_size_mb must not be negative")
+	}
+
+	return cfg, nil
+}
+
+// requireDirs checks that the Maildir is configured, and the storydir if
+// the output needs one.
+func requireDirs(cfg *config.StoryExtractor) error {
+	if cfg.Maildir == "" {
+		return fmt.Errorf("maildir is required (via flag, config, or env)")
+	}
+	if _, err := extractor.ParseOutput(cfg.Output, cfg.Storydir); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newProcessor initializes the LLM extractor and the processor.
+func newProcessor(cfg *config.StoryExtractor, log *slog.Logger) (*extractor.Processor, error) {
+	log.Info("starting story extractor",
+		"maildir", cfg.Maildir,
+		"storydir", cfg.Storydir,
+		"output", cfg.Output,
+		"provider", cfg.LLM.Provider,
+		"model", cfg.LLM.Model)
+
+	var storyExtractor story.Extractor
+	var err error
+	if cfg.LLM.Cache.Enabled {
+		storyExtractor, err = llm.NewCachingExtractor(&cfg.LLM, log)
+	} else {
+		storyExtractor, err = llm.NewExtractor(&cfg.LLM)
+	}
+	if err != nil {
+		return nil, err
+	}
+	storyExtractor = llm.NewRetryingExtractor(storyExtractor, cfg.LLM.Retry, log)
+
+	return extractor.NewProcessor(cfg, log, storyExtractor), nil
+}
+
+// checkResult turns failed processing, or emails that failed, into an error.
+func checkResult(log *slog.Logger, result *extractor.Result, err error) error {
+	if errors.Is(err, budget.ErrExceeded) {
+		log.Warn("stopped at budget limit", "error", err)
+		return err
+	}
+	if err != nil {
+		log.Error("processing failed", "error", err)
+		return err
+	}
+
+	if result.Errors > 0 {
+		return fmt.Errorf("processing completed with %d errors", result.Errors)
+	}
+
+	return nil
+}
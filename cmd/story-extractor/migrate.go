@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fxnn/news/internal/story"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newMigrateCmd(v *viper.Viper, cfgFile *string, runFn RunExtractorFunc) *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate [dir...]",
+		Short: "Upgrade story files to the current format",
+		Long: `Upgrade the story files in the given directories, or else the storydir, to
+the current format: stories extracted before content types existed get the
+content type of the label their teaser starts with, like "Podcast.". Pass
+the UI server's savedir, too, to upgrade the saved stories.
+
+Needs no LLM, and stories already in the current format are left untouched.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadSettings(v, *cfgFile)
+			if err != nil {
+				return err
+			}
+			dirs := args
+			if len(dirs) == 0 {
+				if cfg.Storydir == "" {
+					return fmt.Errorf("storydir is required")
+				}
+				dirs = []string{cfg.Storydir}
+			}
+
+			// Execute injected run function (for testing) or default logic
+			if runFn != nil {
+				return runFn(cfg)
+			}
+
+			for _, dir := range dirs {
+				migrated, err := story.MigrateDir(dir)
+				if err != nil {
+					return fmt.Errorf("failed to migrate %s: %w", dir, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: migrated %d stories\n", dir, migrated)
+			}
+			return nil
+		},
+	}
+}
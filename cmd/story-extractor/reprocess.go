@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fxnn/news/internal/extractor"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dateLayout is the format of the --since and --until flags.
+const dateLayout = "2006-01-02"
+
+func newReprocessCmd(v *viper.Viper, cfgFile *string, runFn RunExtractorFunc) *cobra.Command {
+	var (
+		sel          extractor.Selector
+		since, until string
+		diff         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reprocess",
+		Short: "Extract the stories of selected emails again",
+		Long: `Extract the stories of selected emails again, e.g. after changing the
+prompt or model, and replace their story files in the storydir; --output is
+ignored. Saved stories are kept.
+
+Emails must match all given selectors; use --all to select every email.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if sel.Since, err = parseDate(since); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			if sel.Until, err = parseDate(until); err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+			if sel.IsEmpty() {
+				return fmt.Errorf("no emails selected: use --sender, --since, --until, --message-id, --prompt-version, --zero-stories or --all")
+			}
+
+			cfg, err := loadConfig(v, *cfgFile)
+			if err != nil {
+				return err
+			}
+			if err := requireDirs(cfg); err != nil {
+				return err
+			}
+			if cfg.Storydir == "" {
+				return fmt.Errorf("storydir is required")
+			}
+
+			// Execute injected run function (for testing) or default logic
+			if runFn != nil {
+				return runFn(cfg)
+			}
+
+			log := logger.New(cfg.Verbose)
+			processor, err := newProcessor(cfg, log)
+			if err != nil {
+				return err
+			}
+
+			var diffOut io.Writer
+			if diff {
+				diffOut = cmd.OutOrStdout()
+			}
+			result, err := processor.Reprocess(sel, diffOut)
+			return checkResult(log, result, err)
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&sel.All, "all", false, "Reprocess all emails")
+	f.StringVar(&sel.Sender, "sender", "", "Select emails whose sender address or name contains this text")
+	f.StringVar(&since, "since", "", "Select emails sent on or after this date (YYYY-MM-DD)")
+	f.StringVar(&until, "until", "", "Select emails sent before this date (YYYY-MM-DD)")
+	f.StringSliceVar(&sel.MessageIDs, "message-id", nil, "Select emails by Message-ID (repeatable)")
+	f.StringVar(&sel.PromptVersion, "prompt-version", "", "Select emails last extracted with this prompt version, e.g. default@1a2b3c4d")
+	f.BoolVar(&sel.ZeroStories, "zero-stories", false, "Select emails whose last extraction found no stories")
+	f.BoolVar(&diff, "diff", false, "Print removed (-), added (+) and changed (~) stories")
+
+	return cmd
+}
+
+// parseDate parses a date flag in local time; empty means no date.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateLayout, s, time.Local)
+}
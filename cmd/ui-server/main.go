@@ -8,6 +8,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/fxnn/news/internal/config"
@@ -144,6 +146,27 @@ func handleStories(w http.ResponseWriter, r *http.Request, storydir, savedir str
 		return
 	}
 
+	query := r.URL.Query()
+	if tags := query["tag"]; len(tags) > 0 {
+		stories = filterByTags(stories, tags)
+	}
+	include, err := parseContentTypes(query["type"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(include) > 0 {
+		stories = filterByContentType(stories, include, true)
+	}
+	exclude, err := parseContentTypes(query["exclude_type"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(exclude) > 0 {
+		stories = filterByContentType(stories, exclude, false)
+	}
+
 	savedSet := map[string]bool{}
 	if savedir != "" {
 		var err error
@@ -166,6 +189,44 @@ func handleStories(w http.ResponseWriter, r *http.Request, storydir, savedir str
 	}
 }
 
+// filterByTags keeps the stories that have any of the tags, ignoring case.
+func filterByTags(stories []story.Story, tags []string) []story.Story {
+	filtered := make([]story.Story, 0, len(stories))
+	for _, s := range stories {
+		if slices.ContainsFunc(s.Tags, func(tag string) bool {
+			return slices.ContainsFunc(tags, func(want string) bool { return strings.EqualFold(tag, want) })
+		}) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// parseContentTypes validates the content types of a query parameter.
+func parseContentTypes(values []string) ([]string, error) {
+	types := make([]string, 0, len(values))
+	for _, v := range values {
+		t, ok := story.ParseContentType(v)
+		if !ok {
+			return nil, fmt.Errorf("unknown content type %q", v)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// filterByContentType keeps the stories of the content types, or with keep
+// set to false, the stories of other content types.
+func filterByContentType(stories []story.Story, types []string, keep bool) []story.Story {
+	filtered := make([]story.Story, 0, len(stories))
+	for _, s := range stories {
+		if slices.Contains(types, s.ContentType) == keep {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
 func handleSaveStory(w http.ResponseWriter, r *http.Request, storydir, savedir string) {
 	filename := r.PathValue("filename")
 
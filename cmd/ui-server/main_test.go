@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -283,6 +284,106 @@ func TestHandleStories_NonExistentSavedirIsEmpty(t *testing.T) {
 	}
 }
 
+func TestHandleStories_FilterByTag(t *testing.T) {
+	storydir := t.TempDir()
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	testStories := []story.Story{
+		{Headline: "AI Story", URL: "https://example.com/1", FromEmail: "test@example.com", Date: date, Tags: []string{"ai", "business"}},
+		{Headline: "Security Story", URL: "https://example.com/2", FromEmail: "test@example.com", Date: date, Tags: []string{"security"}},
+		{Headline: "Untagged Story", URL: "https://example.com/3", FromEmail: "test@example.com", Date: date},
+	}
+	if err := story.WriteStoriesToDir(storydir, "<test@example.com>", date, testStories); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"AI Story", "Security Story", "Untagged Story"}},
+		{"?tag=AI", []string{"AI Story"}},
+		{"?tag=ai&tag=security", []string{"AI Story", "Security Story"}},
+		{"?tag=climate", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/stories"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+
+			handleStories(w, req, storydir, "")
+
+			var stories []storyResponse
+			if err := json.NewDecoder(w.Body).Decode(&stories); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			got := make([]string, 0, len(stories))
+			for _, s := range stories {
+				got = append(got, s.Headline)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Got stories %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStories_FilterByContentType(t *testing.T) {
+	storydir := t.TempDir()
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	testStories := []story.Story{
+		{Headline: "Article", URL: "https://example.com/1", FromEmail: "test@example.com", Date: date, ContentType: story.ContentArticle},
+		{Headline: "Podcast", URL: "https://example.com/2", FromEmail: "test@example.com", Date: date, ContentType: story.ContentPodcast},
+		{Headline: "Video", URL: "https://example.com/3", FromEmail: "test@example.com", Date: date, ContentType: story.ContentVideo},
+		{Headline: "Unmigrated Podcast", Teaser: "Podcast. An episode.", URL: "https://example.com/4", FromEmail: "test@example.com", Date: date},
+	}
+	if err := story.WriteStoriesToDir(storydir, "<test@example.com>", date, testStories); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?type=article", []string{"Article"}},
+		{"?type=Podcast", []string{"Podcast", "Unmigrated Podcast"}},
+		{"?exclude_type=podcast&exclude_type=video", []string{"Article"}},
+		{"?type=article&type=video&exclude_type=video", []string{"Article"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/stories"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+
+			handleStories(w, req, storydir, "")
+
+			var stories []storyResponse
+			if err := json.NewDecoder(w.Body).Decode(&stories); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			got := make([]string, 0, len(stories))
+			for _, s := range stories {
+				got = append(got, s.Headline)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Got stories %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStories_UnknownContentType(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/stories?exclude_type=movie", http.NoBody)
+	w := httptest.NewRecorder()
+
+	handleStories(w, req, t.TempDir(), "")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestHandleSaveStory_Success(t *testing.T) {
 	storydir := t.TempDir()
 	savedir := t.TempDir()
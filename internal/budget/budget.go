@@ -0,0 +1,197 @@
+// Package budget caps what the story extractor spends on the LLM, per run
+// and per day. The daily spend is kept in a small JSON state file, so that
+// it persists across runs started from cron.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// StateFilename is the name of the state file within the storydir. Being a
+// dotfile, it is skipped by readers of the story files.
+const StateFilename = ".budget.json"
+
+// ErrExceeded is wrapped by errors reporting that a limit was reached.
+var ErrExceeded = errors.New("budget exceeded")
+
+// Limits are the spending limits; zero means no limit.
+type Limits struct {
+	// MaxCostPerRun is in USD
+	MaxCostPerRun   float64
+	MaxTokensPerDay int
+}
+
+// state is the daily spend persisted in the state file.
+type state struct {
+	// Date is the local day the tokens were spent on, as YYYY-MM-DD
+	Date   string  `json:"date"`
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"cost_usd"`
+}
+
+// Budget tracks the spend of a run against the limits. It is safe for
+// concurrent use. A nil Budget has no limits.
+type Budget struct {
+	mu      sync.Mutex
+	path    string
+	limits  Limits
+	runCost float64
+	today   state
+	// reserved is the estimated spend of extractions in progress
+	reserved Reservation
+	now      func() time.Time
+}
+
+// Reservation is the estimated spend of an extraction in progress, held
+// from Reserve until Settle.
+type Reservation struct {
+	tokens int
+	cost   float64
+}
+
+// Open reads the daily spend from the state file at path. A missing file,
+// or one from an earlier day, starts the day at zero. An empty path keeps
+// the spend in memory only, which suffices for the per-run limit.
+func Open(path string, limits Limits) (*Budget, error) {
+	return open(path, limits, time.Now)
+}
+
+func open(path string, limits Limits, now func() time.Time) (*Budget, error) {
+	b := &Budget{path: path, limits: limits, now: now}
+
+	if path == "" {
+		b.rollOver()
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is the configured budget state file
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read budget state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &b.today); err != nil {
+			return nil, fmt.Errorf("failed to parse budget state %s: %w", path, err)
+		}
+	}
+	b.rollOver()
+
+	return b, nil
+}
+
+// Check returns an error wrapping ErrExceeded if a limit has been reached.
+func (b *Budget) Check() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.check()
+}
+
+// Reserve checks the limits like Check, and reserves the estimated tokens
+// and cost in USD of an extraction about to start, so that concurrent
+// extractions can't all pass the check before any of them spent anything.
+// The reservation counts against the limits until it is settled.
+func (b *Budget) Reserve(tokens int, cost float64) (Reservation, error) {
+	if b == nil {
+		return Reservation{}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.check(); err != nil {
+		return Reservation{}, err
+	}
+	r := Reservation{tokens: tokens, cost: cost}
+	b.reserved.tokens += r.tokens
+	b.reserved.cost += r.cost
+	return r, nil
+}
+
+// Settle releases a reservation and records the tokens and cost actually
+// spent, like Add.
+func (b *Budget) Settle(r Reservation, tokens int, cost float64) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reserved.tokens -= r.tokens
+	b.reserved.cost -= r.cost
+	return b.add(tokens, cost)
+}
+
+// check returns an error wrapping ErrExceeded if the spend, including the
+// reservations, has reached a limit. b.mu must be held.
+func (b *Budget) check() error {
+	b.rollOver()
+	if cost := b.runCost + b.reserved.cost; b.limits.MaxCostPerRun > 0 && cost >= b.limits.MaxCostPerRun {
+		return fmt.Errorf("%w: spent $%.4f of max_cost_per_run $%.4f", ErrExceeded, cost, b.limits.MaxCostPerRun)
+	}
+	if tokens := b.today.Tokens + b.reserved.tokens; b.limits.MaxTokensPerDay > 0 && tokens >= b.limits.MaxTokensPerDay {
+		return fmt.Errorf("%w: used %d of max_tokens_per_day %d", ErrExceeded, tokens, b.limits.MaxTokensPerDay)
+	}
+	return nil
+}
+
+// Add records spent tokens and their cost in USD, and saves the daily spend.
+func (b *Budget) Add(tokens int, cost float64) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.add(tokens, cost)
+}
+
+// add records spent tokens and their cost. b.mu must be held.
+func (b *Budget) add(tokens int, cost float64) error {
+	b.rollOver()
+	b.runCost += cost
+	b.today.Tokens += tokens
+	b.today.Cost += cost
+
+	return b.save()
+}
+
+// rollOver starts a new day once the date changed.
+func (b *Budget) rollOver() {
+	if date := b.now().Format(time.DateOnly); b.today.Date != date {
+		b.today = state{Date: date}
+	}
+}
+
+// save writes the state file atomically (temp file + rename).
+func (b *Budget) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(b.today)
+	if err != nil {
+		return fmt.Errorf("failed to encode budget state: %w", err)
+	}
+
+	tmpPath := b.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write budget state: %w", err)
+	}
+	if err := os.Rename(tmpPath, b.path); err != nil {
+		_ = os.Remove(tmpPath) //nolint:errcheck // Best effort cleanup in error path
+		return fmt.Errorf("failed to write budget state: %w", err)
+	}
+	return nil
+}
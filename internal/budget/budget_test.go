@@ -0,0 +1,116 @@
+package budget
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBudget_MaxCostPerRun(t *testing.T) {
+	b, err := Open(filepath.Join(t.TempDir(), StateFilename), Limits{MaxCostPerRun: 0.01})
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+
+	if err := b.Add(1000, 0.006); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if err := b.Check(); err != nil {
+		t.Errorf("Check() after $0.006 = %v, want nil", err)
+	}
+	if err := b.Add(1000, 0.006); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if err := b.Check(); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Check() after $0.012 = %v, want ErrExceeded", err)
+	}
+}
+
+func TestBudget_MaxTokensPerDayPersistsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFilename)
+	now := time.Date(2024, 5, 14, 7, 30, 0, 0, time.Local)
+	clock := func() time.Time { return now }
+	limits := Limits{MaxTokensPerDay: 1500}
+
+	first, err := open(path, limits, clock)
+	if err != nil {
+		t.Fatalf("open() unexpected error: %v", err)
+	}
+	if err := first.Add(1000, 0); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+
+	// A later run on the same day continues from the saved spend
+	second, err := open(path, limits, clock)
+	if err != nil {
+		t.Fatalf("open() unexpected error: %v", err)
+	}
+	if err := second.Check(); err != nil {
+		t.Errorf("Check() after 1000 tokens = %v, want nil", err)
+	}
+	if err := second.Add(600, 0); err != nil {
+		t.Fatalf("Add() unexpected error: %v", err)
+	}
+	if err := second.Check(); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Check() after 1600 tokens = %v, want ErrExceeded", err)
+	}
+
+	// The next day starts at zero
+	now = now.Add(24 * time.Hour)
+	third, err := open(path, limits, clock)
+	if err != nil {
+		t.Fatalf("open() unexpected error: %v", err)
+	}
+	if err := third.Check(); err != nil {
+		t.Errorf("Check() on the next day = %v, want nil", err)
+	}
+}
+
+func TestBudget_ReservationsCountAgainstLimits(t *testing.T) {
+	b, err := Open("", Limits{MaxTokensPerDay: 1500})
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+
+	// Concurrent extractions can't all start before any of them spent anything
+	first, err := b.Reserve(1000, 0)
+	if err != nil {
+		t.Fatalf("Reserve() unexpected error: %v", err)
+	}
+	second, err := b.Reserve(1000, 0)
+	if err != nil {
+		t.Fatalf("Reserve() with 1000 tokens reserved = %v, want nil", err)
+	}
+	if _, err := b.Reserve(1000, 0); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Reserve() with 2000 tokens reserved = %v, want ErrExceeded", err)
+	}
+
+	// Settling replaces the estimate by the actual spend
+	if err := b.Settle(first, 200, 0); err != nil {
+		t.Fatalf("Settle() unexpected error: %v", err)
+	}
+	if err := b.Settle(second, 200, 0); err != nil {
+		t.Fatalf("Settle() unexpected error: %v", err)
+	}
+	if err := b.Check(); err != nil {
+		t.Errorf("Check() after 400 tokens = %v, want nil", err)
+	}
+}
+
+func TestBudget_NilHasNoLimits(t *testing.T) {
+	var b *Budget
+	if err := b.Add(1_000_000, 100); err != nil {
+		t.Errorf("Add() unexpected error: %v", err)
+	}
+	if err := b.Check(); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+	r, err := b.Reserve(1_000_000, 100)
+	if err != nil {
+		t.Errorf("Reserve() = %v, want nil", err)
+	}
+	if err := b.Settle(r, 1_000_000, 100); err != nil {
+		t.Errorf("Settle() unexpected error: %v", err)
+	}
+}
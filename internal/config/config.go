@@ -4,20 +4,53 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // StoryExtractor configuration for the extraction CLI tool
 type StoryExtractor struct {
-	LLM        LLM    `mapstructure:"llm"`
-	Maildir    string `mapstructure:"maildir"`
-	Storydir   string `mapstructure:"storydir"`
+	LLM      LLM    `mapstructure:"llm"`
+	Maildir  string `mapstructure:"maildir"`
+	Storydir string `mapstructure:"storydir"`
+	// Output selects where stories go: dir, stdout (JSON Lines) or both;
+	// empty uses dir if a storydir is set, stdout otherwise
+	Output string `mapstructure:"output"`
+	// Ledger is the path of the ledger file; empty uses .ledger.jsonl in the
+	// storydir, or no ledger without one
+	Ledger     string `mapstructure:"ledger"`
 	Limit      int    `mapstructure:"limit"`
 	Verbose    bool   `mapstructure:"verbose"`
 	LogHeaders bool   `mapstructure:"log_headers"`
 	LogBodies  bool   `mapstructure:"log_bodies"`
 	LogStories bool   `mapstructure:"log_stories"`
+	// BodyPreference selects the MIME alternative sent to the LLM:
+	// plain-first, html-first, longest or both
+	BodyPreference string `mapstructure:"body_preference"`
+	// URLValidation decides what happens to stories whose URL does not appear
+	// in the email: drop, flag or off
+	URLValidation string `mapstructure:"url_validation"`
+	URLs          URLs   `mapstructure:"urls"`
+	// Concurrency is the number of emails processed in parallel
+	Concurrency int `mapstructure:"concurrency"`
+	// MaxCostPerRun stops a run once it spent this many USD; 0 means no limit
+	MaxCostPerRun float64 `mapstructure:"max_cost_per_run"`
+	// MaxTokensPerDay stops runs once the prompt and completion tokens of the
+	// day reach this; 0 means no limit
+	MaxTokensPerDay int `mapstructure:"max_tokens_per_day"`
+	// BudgetState is the path of the file keeping the day's spend; empty uses
+	// .budget.json in the storydir
+	BudgetState string `mapstructure:"budget_state"`
+}
+
+// URLs configures how story URLs are canonicalized before saving
+type URLs struct {
+	// Canonicalize unwraps tracker links offline and strips tracking parameters
+	Canonicalize bool `mapstructure:"canonicalize"`
+	// ResolveRedirects sends HEAD requests to click trackers that can't be unwrapped offline
+	ResolveRedirects bool          `mapstructure:"resolve_redirects"`
+	ResolveTimeout   time.Duration `mapstructure:"resolve_timeout"`
 }
 
 // UiServer configuration for the web server
@@ -33,7 +66,110 @@ type LLM struct {
 	Provider string `mapstructure:"provider"`
 	Model    string `mapstructure:"model"`
 	APIKey   string `mapstructure:"api_key"`
-	BaseURL  string `mapstructure:"base_url"`
+	// BaseURL overrides the provider's API endpoint; empty uses the provider default
+	BaseURL string `mapstructure:"base_url"`
+	// MaxOutputTokens caps the model's reply; 0 uses the provider default
+	MaxOutputTokens int `mapstructure:"max_output_tokens"`
+	// Prompt selects the prompt variant: default or compact (for small local models);
+	// empty uses the provider default
+	Prompt string `mapstructure:"prompt"`
+	// PromptFile is a text/template file used instead of the built-in prompt
+	PromptFile string `mapstructure:"prompt_file"`
+	// PromptOverrides select other prompts for some newsletters; the first
+	// matching one wins
+	PromptOverrides []PromptOverride `mapstructure:"prompt_overrides"`
+	// ChunkSize splits email bodies longer than this many bytes into
+	// separate requests; 0 uses the provider default, negative disables chunking
+	ChunkSize int `mapstructure:"chunk_size"`
+	// StructuredOutput selects how OpenAI-compatible providers constrain replies:
+	// json_schema (default, falls back automatically) or json_object
+	StructuredOutput string `mapstructure:"structured_output"`
+	// Models holds settings for specific models, overriding the ones above
+	Models []Model `mapstructure:"models"`
+	Retry  Retry   `mapstructure:"retry"`
+	// RateLimit is shared by all concurrent requests to the provider
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	Cache     Cache     `mapstructure:"cache"`
+	Tags      Tags      `mapstructure:"tags"`
+}
+
+// DefaultTagVocabulary are the topic tags used unless llm.tags.vocabulary is
+// configured.
+var DefaultTagVocabulary = []string{
+	"ai", "programming", "security", "science", "business",
+	"politics", "society", "culture", "health", "climate",
+}
+
+// Tags configures the topic tags the model assigns to stories ([llm.tags]).
+type Tags struct {
+	// Vocabulary are the allowed tags; tags outside of it are dropped
+	Vocabulary []string `mapstructure:"vocabulary"`
+	// AllowNew lets the model propose tags outside of the vocabulary
+	AllowNew bool `mapstructure:"allow_new"`
+}
+
+// Cache configures the on-disk cache of extraction results ([llm.cache]),
+// which makes re-running unchanged emails free.
+type Cache struct {
+	// Enabled turns the cache on; it is off by default, since the cached
+	// replies quote the newsletters
+	Enabled bool `mapstructure:"enabled"`
+	// Dir holds the cached results; empty uses story-extractor/responses in
+	// the user's cache directory
+	Dir string `mapstructure:"dir"`
+	// MaxSizeMB caps the size of the cache, evicting the least recently used
+	// results; 0 means no limit
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// Refresh ignores cached results, but still stores the new ones
+	Refresh bool `mapstructure:"refresh"`
+}
+
+// RateLimit caps the load sent to the LLM provider ([llm.rate_limit]);
+// zero means no limit.
+type RateLimit struct {
+	RequestsPerMinute int `mapstructure:"requests_per_minute"`
+	// TokensPerMinute limits prompt tokens, estimated from the prompt length
+	TokensPerMinute int `mapstructure:"tokens_per_minute"`
+}
+
+// Retry configures how failed LLM calls are retried ([llm.retry]). Only
+// rate limits, server errors and network failures are retried.
+type Retry struct {
+	// MaxAttempts caps the calls per email, including the first; 1 disables retries
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the delay before the first retry, doubled for every further one
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	// MaxElapsed caps the total time spent on an email, including waiting
+	MaxElapsed time.Duration `mapstructure:"max_elapsed"`
+}
+
+// PromptOverride selects the prompt for the emails of some newsletters,
+// configured as [[llm.prompt_overrides]]. Patterns use shell glob syntax and
+// match case-insensitively; an email matches if any pattern does.
+type PromptOverride struct {
+	// Senders are matched against the sender address, e.g. "*@example.de"
+	Senders []string `mapstructure:"senders"`
+	// ListIDs are matched against the List-Id header, e.g. "*.example.de"
+	ListIDs []string `mapstructure:"list_ids"`
+	// Prompt names a built-in prompt variant; PromptFile a template file
+	Prompt     string `mapstructure:"prompt"`
+	PromptFile string `mapstructure:"prompt_file"`
+	// Language is passed to the template as .Language, overriding the
+	// email's Content-Language
+	Language string `mapstructure:"language"`
+}
+
+// Model holds settings for a single model, configured as [[llm.models]].
+type Model struct {
+	// Name is matched case-insensitively against LLM.Model
+	Name string `mapstructure:"name"`
+	// ChunkSize overrides LLM.ChunkSize for this model; 0 keeps it
+	ChunkSize int `mapstructure:"chunk_size"`
+	// InputPrice and OutputPrice are in USD per million tokens; they override
+	// the built-in price of the model
+	InputPrice  float64 `mapstructure:"input_price"`
+	OutputPrice float64 `mapstructure:"output_price"`
 }
 
 // SetupStoryExtractor configures defaults for the story extractor
@@ -41,8 +177,36 @@ func SetupStoryExtractor(v *viper.Viper) {
 	v.SetDefault("llm.provider", "openai")
 	v.SetDefault("llm.model", "gpt-4o-mini")
 	v.SetDefault("llm.api_key", "")
-	v.SetDefault("llm.base_url", "https://api.openai.com/v1")
+	v.SetDefault("llm.base_url", "")
+	v.SetDefault("llm.max_output_tokens", 0)
+	v.SetDefault("llm.prompt", "")
+	v.SetDefault("llm.prompt_file", "")
+	v.SetDefault("llm.chunk_size", 0)
+	v.SetDefault("llm.structured_output", "")
+	v.SetDefault("llm.retry.max_attempts", 4)
+	v.SetDefault("llm.retry.initial_backoff", "2s")
+	v.SetDefault("llm.retry.max_backoff", "1m")
+	v.SetDefault("llm.retry.max_elapsed", "10m")
+	v.SetDefault("llm.rate_limit.requests_per_minute", 0)
+	v.SetDefault("llm.rate_limit.tokens_per_minute", 0)
+	v.SetDefault("llm.cache.enabled", false)
+	v.SetDefault("llm.cache.dir", "")
+	v.SetDefault("llm.cache.max_size_mb", 100)
+	v.SetDefault("llm.cache.refresh", false)
+	v.SetDefault("llm.tags.vocabulary", DefaultTagVocabulary)
+	v.SetDefault("llm.tags.allow_new", false)
+	v.SetDefault("output", "")
+	v.SetDefault("ledger", "")
+	v.SetDefault("concurrency", 1)
+	v.SetDefault("max_cost_per_run", 0)
+	v.SetDefault("max_tokens_per_day", 0)
+	v.SetDefault("budget_state", "")
 	v.SetDefault("verbose", false)
+	v.SetDefault("body_preference", "plain-first")
+	v.SetDefault("url_validation", "drop")
+	v.SetDefault("urls.canonicalize", true)
+	v.SetDefault("urls.resolve_redirects", false)
+	v.SetDefault("urls.resolve_timeout", "10s")
 
 	v.SetEnvPrefix("STORY_EXTRACTOR")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -27,6 +29,45 @@ func TestLoadStoryExtractor_Defaults(t *testing.T) {
 	if cfg.Verbose != false {
 		t.Errorf("Verbose = %v, want false", cfg.Verbose)
 	}
+	if cfg.BodyPreference != "plain-first" {
+		t.Errorf("BodyPreference = %v, want plain-first", cfg.BodyPreference)
+	}
+	if !cfg.URLs.Canonicalize || cfg.URLs.ResolveRedirects {
+		t.Errorf("URLs = %+v, want offline canonicalization by default", cfg.URLs)
+	}
+	if cfg.URLs.ResolveTimeout != 10*time.Second {
+		t.Errorf("URLs.ResolveTimeout = %v, want 10s", cfg.URLs.ResolveTimeout)
+	}
+	wantRetry := Retry{MaxAttempts: 4, InitialBackoff: 2 * time.Second, MaxBackoff: time.Minute, MaxElapsed: 10 * time.Minute}
+	if cfg.LLM.Retry != wantRetry {
+		t.Errorf("LLM.Retry = %+v, want %+v", cfg.LLM.Retry, wantRetry)
+	}
+	if !slices.Equal(cfg.LLM.Tags.Vocabulary, DefaultTagVocabulary) || cfg.LLM.Tags.AllowNew {
+		t.Errorf("LLM.Tags = %+v, want the default vocabulary", cfg.LLM.Tags)
+	}
+}
+
+func TestLoadStoryExtractor_Tags(t *testing.T) {
+	configContent := `
+[llm.tags]
+vocabulary = ["go", "databases"]
+allow_new = true
+`
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	SetupStoryExtractor(v)
+	cfg, err := LoadStoryExtractor(v, configPath)
+	if err != nil {
+		t.Fatalf("LoadStoryExtractor() error = %v", err)
+	}
+
+	if !slices.Equal(cfg.LLM.Tags.Vocabulary, []string{"go", "databases"}) || !cfg.LLM.Tags.AllowNew {
+		t.Errorf("LLM.Tags = %+v, want the configured vocabulary with new tags", cfg.LLM.Tags)
+	}
 }
 
 func TestLoadStoryExtractor_ConfigFile(t *testing.T) {
@@ -61,6 +102,47 @@ api_key = "test-key"
 	}
 }
 
+func TestLoadStoryExtractor_ModelSettings(t *testing.T) {
+	tmpDir := t.TempDir()
+	configContent := `
+[llm]
+model = "gpt-4.1-mini"
+chunk_size = 30000
+
+[[llm.models]]
+name = "gpt-4.1-mini"
+chunk_size = 80000
+
+[[llm.models]]
+name = "llama3.1:8b"
+chunk_size = 8000
+`
+	configPath := filepath.Join(tmpDir, "config.toml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	SetupStoryExtractor(v)
+	cfg, err := LoadStoryExtractor(v, configPath)
+	if err != nil {
+		t.Fatalf("LoadStoryExtractor() error = %v", err)
+	}
+
+	want := []Model{{Name: "gpt-4.1-mini", ChunkSize: 80000}, {Name: "llama3.1:8b", ChunkSize: 8000}}
+	if len(cfg.LLM.Models) != len(want) {
+		t.Fatalf("LLM.Models = %+v, want %+v", cfg.LLM.Models, want)
+	}
+	for i := range want {
+		if cfg.LLM.Models[i] != want[i] {
+			t.Errorf("LLM.Models[%d] = %+v, want %+v", i, cfg.LLM.Models[i], want[i])
+		}
+	}
+	if cfg.LLM.ChunkSize != 30000 {
+		t.Errorf("LLM.ChunkSize = %d, want 30000", cfg.LLM.ChunkSize)
+	}
+}
+
 func TestLoadStoryExtractor_EnvVars(t *testing.T) {
 	if err := os.Setenv("STORY_EXTRACTOR_LLM_PROVIDER", "gemini"); err != nil {
 		t.Fatalf("Failed to set STORY_EXTRACTOR_LLM_PROVIDER: %v", err)
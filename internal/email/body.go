@@ -0,0 +1,70 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BodyPreference selects which text alternative of a message becomes Email.Body.
+type BodyPreference string
+
+const (
+	// PreferPlain uses the text/plain alternative, falling back to HTML.
+	PreferPlain BodyPreference = "plain-first"
+	// PreferHTML uses the text/html alternative, falling back to plain text.
+	// Useful when plain text alternatives are stubs like "view this email in your browser".
+	PreferHTML BodyPreference = "html-first"
+	// PreferLongest uses whichever alternative yields more text.
+	PreferLongest BodyPreference = "longest"
+	// PreferBoth concatenates the plain text and the HTML alternative.
+	PreferBoth BodyPreference = "both"
+)
+
+// Body parts recorded in Email.BodyPart.
+const (
+	BodyPartNone  = ""
+	BodyPartPlain = "plain"
+	BodyPartHTML  = "html"
+	BodyPartBoth  = "both"
+)
+
+// ParseBodyPreference validates a body preference from configuration.
+// An empty string selects the default, PreferPlain.
+func ParseBodyPreference(s string) (BodyPreference, error) {
+	switch p := BodyPreference(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PreferPlain, nil
+	case PreferPlain, PreferHTML, PreferLongest, PreferBoth:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown body preference %q (want %s, %s, %s or %s)",
+			s, PreferPlain, PreferHTML, PreferLongest, PreferBoth)
+	}
+}
+
+// selectBody picks the message body from the plain text and the converted
+// HTML alternative according to the preference, and reports which part was used.
+func selectBody(plain, html string, pref BodyPreference) (body, part string) {
+	switch {
+	case plain == "" && html == "":
+		return "", BodyPartNone
+	case plain == "":
+		return html, BodyPartHTML
+	case html == "":
+		return plain, BodyPartPlain
+	}
+
+	switch pref {
+	case PreferHTML:
+		return html, BodyPartHTML
+	case PreferLongest:
+		if len(strings.TrimSpace(html)) > len(strings.TrimSpace(plain)) {
+			return html, BodyPartHTML
+		}
+		return plain, BodyPartPlain
+	case PreferBoth:
+		return strings.TrimSpace(plain) + "\n\n" + strings.TrimSpace(html), BodyPartBoth
+	default:
+		return plain, BodyPartPlain
+	}
+}
@@ -0,0 +1,74 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBodyPreference(t *testing.T) {
+	tests := []struct {
+		input string
+		want  BodyPreference
+	}{
+		{"", PreferPlain},
+		{"plain-first", PreferPlain},
+		{"HTML-First", PreferHTML},
+		{" longest ", PreferLongest},
+		{"both", PreferBoth},
+	}
+	for _, tt := range tests {
+		got, err := ParseBodyPreference(tt.input)
+		if err != nil {
+			t.Errorf("ParseBodyPreference(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBodyPreference(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := ParseBodyPreference("markdown"); err == nil {
+		t.Error("ParseBodyPreference(markdown) should return an error")
+	}
+}
+
+func TestSelectBody(t *testing.T) {
+	plain := "View in browser"
+	html := "First story\n\nSecond story with a much longer description"
+
+	tests := []struct {
+		pref     BodyPreference
+		wantPart string
+		contains []string
+	}{
+		{PreferPlain, BodyPartPlain, []string{"View in browser"}},
+		{PreferHTML, BodyPartHTML, []string{"First story"}},
+		{PreferLongest, BodyPartHTML, []string{"Second story"}},
+		{PreferBoth, BodyPartBoth, []string{"View in browser", "First story"}},
+	}
+	for _, tt := range tests {
+		body, part := selectBody(plain, html, tt.pref)
+		if part != tt.wantPart {
+			t.Errorf("selectBody(%v) part = %q, want %q", tt.pref, part, tt.wantPart)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(body, want) {
+				t.Errorf("selectBody(%v) body = %q, should contain %q", tt.pref, body, want)
+			}
+		}
+	}
+}
+
+func TestSelectBodyFallsBackToAvailablePart(t *testing.T) {
+	if body, part := selectBody("", "Only HTML", PreferPlain); part != BodyPartHTML || !strings.Contains(body, "Only HTML") {
+		t.Errorf("selectBody(plain-first) = (%q, %q), want HTML fallback", body, part)
+	}
+
+	if body, part := selectBody("Only plain", "", PreferBoth); part != BodyPartPlain || body != "Only plain" {
+		t.Errorf("selectBody(both) = (%q, %q), want plain fallback", body, part)
+	}
+
+	if body, part := selectBody("", "", PreferHTML); part != BodyPartNone || body != "" {
+		t.Errorf("selectBody() on empty parts = (%q, %q), want empty", body, part)
+	}
+}
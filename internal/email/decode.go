@@ -0,0 +1,88 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// readBody reads a MIME entity body, undoes its Content-Transfer-Encoding and
+// converts it from the declared charset to UTF-8. For HTML without a declared
+// charset that is not valid UTF-8, the charset is sniffed from <meta> tags.
+func readBody(r io.Reader, transferEncoding, mediaType, charsetLabel string) (string, error) {
+	raw, err := io.ReadAll(transferDecoder(r, transferEncoding))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s body: %w", transferEncoding, err)
+	}
+
+	return toUTF8(raw, mediaType, charsetLabel), nil
+}
+
+// transferDecoder wraps r with a decoder for the given Content-Transfer-Encoding.
+// Identity encodings (7bit, 8bit, binary) and unknown values pass through unchanged.
+func transferDecoder(r io.Reader, transferEncoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "base64":
+		// The base64 decoder ignores \r and \n, so line-wrapped content works as is
+		return base64.NewDecoder(base64.StdEncoding, &base64Filter{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// toUTF8 converts content from the given charset to UTF-8. Unknown charsets
+// leave the content unchanged, since garbled umlauts beat losing the email.
+func toUTF8(content []byte, mediaType, charsetLabel string) string {
+	label := strings.ToLower(strings.TrimSpace(charsetLabel))
+	if label == "" && mediaType == "text/html" && !utf8.Valid(content) {
+		_, name, _ := charset.DetermineEncoding(content, mediaType)
+		label = name
+	}
+
+	switch label {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return string(content)
+	}
+
+	reader, err := charset.NewReaderLabel(label, bytes.NewReader(content))
+	if err != nil {
+		return string(content)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return string(content)
+	}
+
+	return string(decoded)
+}
+
+// base64Filter drops bytes outside the base64 alphabet (such as trailing
+// whitespace or stray characters some mailers emit) before decoding.
+type base64Filter struct {
+	r io.Reader
+}
+
+func (f *base64Filter) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if isBase64Byte(b) {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+func isBase64Byte(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' ||
+		b == '+' || b == '/' || b == '='
+}
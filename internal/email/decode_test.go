@@ -0,0 +1,61 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadBody_Base64WithLineBreaks(t *testing.T) {
+	body, err := readBody(strings.NewReader("SGVsbG8s\r\nIFdvcmxk\r\nIQ==\r\n"), "BASE64", "text/plain", "")
+	if err != nil {
+		t.Fatalf("readBody() unexpected error: %v", err)
+	}
+	if body != "Hello, World!" {
+		t.Errorf("readBody() = %q, want %q", body, "Hello, World!")
+	}
+}
+
+func TestReadBody_QuotedPrintableSoftLineBreaks(t *testing.T) {
+	body, err := readBody(strings.NewReader("caf=C3=A9 au =\r\nlait"), "quoted-printable", "text/plain", "utf-8")
+	if err != nil {
+		t.Fatalf("readBody() unexpected error: %v", err)
+	}
+	if body != "café au lait" {
+		t.Errorf("readBody() = %q, want %q", body, "café au lait")
+	}
+}
+
+func TestReadBody_IdentityEncodings(t *testing.T) {
+	for _, encoding := range []string{"", "7bit", "8bit", "binary", "x-unknown"} {
+		body, err := readBody(strings.NewReader("plain"), encoding, "text/plain", "")
+		if err != nil {
+			t.Fatalf("readBody(%q) unexpected error: %v", encoding, err)
+		}
+		if body != "plain" {
+			t.Errorf("readBody(%q) = %q, want plain", encoding, body)
+		}
+	}
+}
+
+func TestToUTF8_UnknownCharsetPassesThrough(t *testing.T) {
+	if got := toUTF8([]byte("abc"), "text/plain", "x-no-such-charset"); got != "abc" {
+		t.Errorf("toUTF8() = %q, want abc", got)
+	}
+}
+
+func TestToUTF8_SniffsHTMLMetaCharset(t *testing.T) {
+	content := []byte("<html><head><meta charset=\"iso-8859-1\"></head><body>M\xfcnchen</body></html>")
+
+	got := toUTF8(content, "text/html", "")
+	if !strings.Contains(got, "München") {
+		t.Errorf("toUTF8() = %q, should contain München", got)
+	}
+}
+
+func TestToUTF8_KeepsValidUTF8HTMLWithoutCharset(t *testing.T) {
+	content := []byte("<html><body>München</body></html>")
+
+	if got := toUTF8(content, "text/html", ""); got != string(content) {
+		t.Errorf("toUTF8() = %q, want unchanged content", got)
+	}
+}
@@ -0,0 +1,113 @@
+package email
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Link describes a hyperlink found in an email.
+type Link struct {
+	// URL is the link target as written in the email.
+	URL string
+	// Text is the anchor text, or the image alt text for image links.
+	Text string
+	// Heading is the text of the closest heading preceding the link.
+	Heading string
+	// Position is the zero-based index of the link in document order.
+	Position int
+	// IsImage is set for links whose only content is an image.
+	IsImage bool
+	// IsUnsubscribe is set for unsubscribe and subscription management links.
+	IsUnsubscribe bool
+	// IsSocial is set for share buttons and links to social media profiles.
+	IsSocial bool
+}
+
+func newLink(href, text, heading string, position int, isImage bool) Link {
+	return Link{
+		URL:           href,
+		Text:          text,
+		Heading:       heading,
+		Position:      position,
+		IsImage:       isImage,
+		IsUnsubscribe: isUnsubscribeLink(href, text),
+		IsSocial:      isSocialLink(href),
+	}
+}
+
+// textURLPattern matches http(s) URLs in plain text, stopping at whitespace
+// and characters that commonly enclose URLs.
+var textURLPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// findTextLinks collects the URLs written out in a plain text body.
+func findTextLinks(text string) []Link {
+	var links []Link
+	for _, match := range textURLPattern.FindAllString(text, -1) {
+		// Sentence punctuation directly after a URL is not part of it
+		href := strings.TrimRight(match, ".,;:!?")
+		links = append(links, newLink(href, "", "", len(links), false))
+	}
+	return links
+}
+
+// unsubscribeMarkers identify unsubscribe and preference links, in the
+// languages our newsletters are written in.
+var unsubscribeMarkers = []string{
+	"unsubscribe", "opt-out", "optout", "manage preferences", "email preferences",
+	"update your preferences", "subscription preferences",
+	"abmelden", "abbestellen", "austragen", "désinscri", "desinscri",
+}
+
+func isUnsubscribeLink(href, text string) bool {
+	haystack := strings.ToLower(href + " " + text)
+	for _, marker := range unsubscribeMarkers {
+		if strings.Contains(haystack, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// socialHosts are social networks whose profile and share links appear in
+// newsletter footers.
+var socialHosts = []string{
+	"facebook.com", "twitter.com", "x.com", "instagram.com", "linkedin.com",
+	"tiktok.com", "threads.net", "bsky.app", "pinterest.com", "mastodon.social",
+}
+
+// isSocialLink reports whether href is a share button or a link to a social
+// media profile. Individual posts (e.g. a LinkedIn article) can be stories in
+// their own right, so only profile roots and share endpoints count.
+func isSocialLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	matched := false
+	for _, social := range socialHosts {
+		if host == social || strings.HasSuffix(host, "."+social) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	path := strings.ToLower(strings.Trim(u.Path, "/"))
+	if path == "" || !strings.Contains(path, "/") {
+		return true
+	}
+	if strings.Contains(path, "share") {
+		return true
+	}
+	for _, prefix := range []string{"intent/", "company/", "in/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
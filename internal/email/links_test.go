@@ -0,0 +1,88 @@
+package email
+
+import "testing"
+
+func TestConvertHTML_CollectsLinks(t *testing.T) {
+	html := `<html><body>
+<h1>Top stories</h1>
+<p><a href="https://example.com/one">First <b>story</b></a></p>
+<h2>More <a href="https://example.com/section">news</a></h2>
+<p><a href="https://example.com/two"><img src="two.png" alt="Second story"></a></p>
+<p><a href="https://example.com/unsubscribe?id=1">Click here</a>
+<a href="https://twitter.com/weeklybyte">Twitter</a>
+<a href="mailto:hi@example.com">Mail us</a></p>
+</body></html>`
+
+	_, links := convertHTML(html)
+
+	want := []Link{
+		{URL: "https://example.com/one", Text: "First story", Heading: "Top stories", Position: 0},
+		{URL: "https://example.com/section", Text: "news", Heading: "More news", Position: 1},
+		{URL: "https://example.com/two", Text: "Second story", Heading: "More news", Position: 2, IsImage: true},
+		{URL: "https://example.com/unsubscribe?id=1", Text: "Click here", Heading: "More news", Position: 3, IsUnsubscribe: true},
+		{URL: "https://twitter.com/weeklybyte", Text: "Twitter", Heading: "More news", Position: 4, IsSocial: true},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("convertHTML() returned %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("links[%d] = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+}
+
+func TestFindTextLinks(t *testing.T) {
+	text := "Read this: https://example.com/a, and (https://example.com/b).\nAlso <https://example.com/c?x=1>"
+
+	links := findTextLinks(text)
+
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c?x=1"}
+	if len(links) != len(want) {
+		t.Fatalf("findTextLinks() returned %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i, url := range want {
+		if links[i].URL != url || links[i].Position != i {
+			t.Errorf("links[%d] = %+v, want URL %s at position %d", i, links[i], url, i)
+		}
+	}
+}
+
+func TestIsUnsubscribeLink(t *testing.T) {
+	tests := []struct {
+		href, text string
+		want       bool
+	}{
+		{"https://example.com/u", "Unsubscribe", true},
+		{"https://example.com/newsletter/abmelden", "hier", true},
+		{"https://example.us1.list-manage.com/unsubscribe?u=1", "", true},
+		{"https://example.com/profile", "Manage preferences", true},
+		{"https://example.com/article", "Great article", false},
+	}
+	for _, tt := range tests {
+		if got := isUnsubscribeLink(tt.href, tt.text); got != tt.want {
+			t.Errorf("isUnsubscribeLink(%q, %q) = %v, want %v", tt.href, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsSocialLink(t *testing.T) {
+	tests := []struct {
+		href string
+		want bool
+	}{
+		{"https://www.facebook.com/weeklybyte", true},
+		{"https://twitter.com/intent/tweet?url=x", true},
+		{"https://www.linkedin.com/company/weeklybyte/", true},
+		{"https://www.linkedin.com/shareArticle?url=x", true},
+		{"https://x.com/", true},
+		{"https://www.linkedin.com/pulse/some-great-article-author", false},
+		{"https://twitter.com/someone/status/12345", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := isSocialLink(tt.href); got != tt.want {
+			t.Errorf("isSocialLink(%q) = %v, want %v", tt.href, got, tt.want)
+		}
+	}
+}
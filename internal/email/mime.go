@@ -0,0 +1,115 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// maxMIMEDepth bounds the nesting of multipart entities we descend into,
+// protecting against malformed or malicious messages.
+const maxMIMEDepth = 10
+
+// mimeHeader is satisfied by both mail.Header and textproto.MIMEHeader.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// textParts holds the text alternatives found while walking a MIME tree.
+// The first non-empty part of each type wins, which is the main body in
+// multipart/mixed messages where mailing list software appends footer parts.
+type textParts struct {
+	plain string
+	html  string
+}
+
+// walk descends into a MIME entity, collecting text/plain and text/html bodies.
+// Nested multipart/mixed, multipart/alternative and multipart/related entities
+// are traversed recursively; attachments and non-text parts are ignored.
+func (t *textParts) walk(header mimeHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		if depth > 0 && header.Get("Content-Type") != "" {
+			// Skip parts with invalid content type
+			return nil
+		}
+		// Default to plain text if Content-Type is missing or can't be parsed
+		mediaType, params = "text/plain", nil
+	}
+
+	if depth > 0 && isAttachment(header) {
+		return nil
+	}
+
+	if depth == 0 && !strings.HasPrefix(mediaType, "multipart/") && mediaType != "text/html" {
+		// Single-part messages are read as plain text, whatever their type
+		mediaType = "text/plain"
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxMIMEDepth {
+			return nil
+		}
+		return t.walkMultipart(body, params["boundary"], depth)
+	case mediaType == "text/plain" || mediaType == "text/html":
+		text, err := readBody(body, header.Get("Content-Transfer-Encoding"), mediaType, params["charset"])
+		if err != nil {
+			if depth > 0 {
+				// Skip unreadable parts, other alternatives may still work
+				return nil
+			}
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		t.add(mediaType, text)
+	}
+
+	return nil
+}
+
+func (t *textParts) walkMultipart(body io.Reader, boundary string, depth int) error {
+	mr := multipart.NewReader(body, boundary)
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse multipart: %w", err)
+		}
+
+		// multipart.Reader already decodes quoted-printable parts (and removes the
+		// header), so walk only has to deal with base64 and charsets
+		err = t.walk(part.Header, part, depth+1)
+		_ = part.Close() //nolint:errcheck // Best effort close, no action to take on error
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *textParts) add(mediaType, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	switch {
+	case mediaType == "text/plain" && t.plain == "":
+		t.plain = text
+	case mediaType == "text/html" && t.html == "":
+		t.html = text
+	}
+}
+
+// isAttachment reports whether a part is marked as an attachment, e.g. a
+// forwarded .txt or .html file that is not part of the message body.
+func isAttachment(header mimeHeader) bool {
+	disposition, _, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil {
+		return false
+	}
+	return disposition == "attachment"
+}
@@ -0,0 +1,69 @@
+package email
+
+import (
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestTextParts_WalkNestedMultipart(t *testing.T) {
+	email := parseFixture(t, "nested-mixed-alternative-related.eml")
+
+	if !strings.Contains(email.Body, "View this email in your browser") {
+		t.Errorf("Body = %q, should contain the plain text alternative", email.Body)
+	}
+	if strings.Contains(email.Body, "terms must not end up") {
+		t.Errorf("Body = %q, should not contain attachment content", email.Body)
+	}
+}
+
+func TestTextParts_WalkCollectsHTMLFromRelated(t *testing.T) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", `multipart/mixed; boundary="outer"`)
+	body := "--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"inner\"\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: multipart/related; boundary=\"rel\"\r\n\r\n" +
+		"--rel\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Deep HTML</p>\r\n" +
+		"--rel--\r\n" +
+		"--inner--\r\n" +
+		"--outer--\r\n"
+
+	var parts textParts
+	if err := parts.walk(header, strings.NewReader(body), 0); err != nil {
+		t.Fatalf("walk() unexpected error: %v", err)
+	}
+
+	if parts.plain != "" {
+		t.Errorf("plain = %q, want empty", parts.plain)
+	}
+	if !strings.Contains(parts.html, "Deep HTML") {
+		t.Errorf("html = %q, should contain nested HTML part", parts.html)
+	}
+}
+
+func TestTextParts_WalkSkipsEmptyAlternatives(t *testing.T) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", `multipart/alternative; boundary="b"`)
+	body := "--b\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"  \r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Only HTML has content</p>\r\n" +
+		"--b--\r\n"
+
+	var parts textParts
+	if err := parts.walk(header, strings.NewReader(body), 0); err != nil {
+		t.Fatalf("walk() unexpected error: %v", err)
+	}
+
+	if parts.plain != "" {
+		t.Errorf("plain = %q, want empty for whitespace-only part", parts.plain)
+	}
+	if parts.html == "" {
+		t.Error("html should be collected")
+	}
+}
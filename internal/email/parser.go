@@ -3,18 +3,17 @@ package email
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime"
-	"mime/multipart"
 	"net/mail"
 	"os"
 	"strings"
 	"time"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
 // Email represents a parsed email message with extracted metadata.
@@ -25,10 +24,33 @@ type Email struct {
 	FromName  string
 	Date      time.Time
 	MessageID string
+	// ListID is the identifier of the mailing list from the List-Id header,
+	// e.g. "news.example.com"; empty if there is none
+	ListID string
+	// Language is the first language of the Content-Language header, if any
+	Language string
+	// BodyPart records which MIME alternative Body was taken from (see BodyPart* constants).
+	BodyPart string
+	// Links lists the hyperlinks of the HTML alternative in document order, or
+	// the URLs found in the plain text if the email has no HTML alternative.
+	Links []Link
+	// BodyLinks lists the links of the alternative in Body: the URLs found in
+	// the plain text, the hyperlinks of the HTML alternative, or both.
+	BodyLinks []Link
 }
 
-// Parse reads and parses an email from the given reader.
+// Options controls how Parse builds the email body.
+type Options struct {
+	BodyPreference BodyPreference
+}
+
+// Parse reads and parses an email from the given reader, preferring plain text bodies.
 func Parse(r io.Reader) (*Email, error) {
+	return ParseWithOptions(r, Options{BodyPreference: PreferPlain})
+}
+
+// ParseWithOptions reads and parses an email from the given reader.
+func ParseWithOptions(r io.Reader, opts Options) (*Email, error) {
 	msg, err := mail.ReadMessage(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read email: %w", err)
@@ -36,8 +58,8 @@ func Parse(r io.Reader) (*Email, error) {
 
 	email := &Email{}
 
-	// Parse Subject (decode MIME-encoded words)
-	decoder := &mime.WordDecoder{}
+	// Parse Subject (decode MIME-encoded words, converting any charset to UTF-8)
+	decoder := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
 	subject := msg.Header.Get("Subject")
 	decodedSubject, err := decoder.DecodeHeader(subject)
 	if err == nil {
@@ -48,7 +70,8 @@ func Parse(r io.Reader) (*Email, error) {
 	}
 
 	// Parse From
-	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	addressParser := &mail.AddressParser{WordDecoder: decoder}
+	from, err := addressParser.Parse(msg.Header.Get("From"))
 	if err == nil {
 		email.FromEmail = from.Address
 		email.FromName = from.Name
@@ -87,140 +110,208 @@ func Parse(r io.Reader) (*Email, error) {
 			"generated_id", email.MessageID)
 	}
 
+	email.ListID = parseListID(msg.Header.Get("List-Id"))
+	email.Language, _, _ = strings.Cut(msg.Header.Get("Content-Language"), ",")
+	email.Language = strings.TrimSpace(email.Language)
+
 	// Parse Body
-	contentType := msg.Header.Get("Content-Type")
-	mediaType, params, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		// Default to plain text if Content-Type can't be parsed
-		body, err := io.ReadAll(msg.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read body: %w", err)
-		}
-		email.Body = string(body)
-		return email, nil
+	var parts textParts
+	if err := parts.walk(msg.Header, msg.Body, 0); err != nil {
+		return nil, err
 	}
 
-	switch {
-	case strings.HasPrefix(mediaType, "multipart/"):
-		body, err := parseMultipart(msg.Body, params["boundary"])
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse multipart: %w", err)
-		}
-		email.Body = body
-	case mediaType == "text/html":
-		body, err := io.ReadAll(msg.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read HTML body: %w", err)
-		}
-		email.Body = extractTextFromHTML(string(body))
-	default:
-		body, err := io.ReadAll(msg.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read body: %w", err)
-		}
-		email.Body = string(body)
+	var htmlText string
+	var htmlLinks []Link
+	if parts.html != "" {
+		htmlText, htmlLinks = convertHTML(parts.html)
+	}
+	email.Body, email.BodyPart = selectBody(parts.plain, htmlText, opts.BodyPreference)
+
+	email.Links = htmlLinks
+	if parts.html == "" {
+		email.Links = findTextLinks(parts.plain)
+	}
+	switch email.BodyPart {
+	case BodyPartHTML:
+		email.BodyLinks = htmlLinks
+	case BodyPartPlain:
+		email.BodyLinks = findTextLinks(parts.plain)
+	case BodyPartBoth:
+		email.BodyLinks = append(findTextLinks(parts.plain), htmlLinks...)
 	}
 
 	return email, nil
 }
 
-func parseMultipart(body io.Reader, boundary string) (string, error) {
-	mr := multipart.NewReader(body, boundary)
+// parseListID returns the list identifier of a List-Id header, which may be
+// preceded by a description: `Tech News <tech.news.example.com>`.
+func parseListID(header string) string {
+	if start := strings.LastIndex(header, "<"); start >= 0 {
+		if end := strings.Index(header[start:], ">"); end > 0 {
+			return strings.ToLower(strings.TrimSpace(header[start+1 : start+end]))
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(header))
+}
 
-	var plainText string
-	var htmlText string
+// extractTextFromHTML converts an HTML document to plain text. Hyperlinks are
+// kept as Markdown-style [text](url) so that link targets survive the
+// conversion and reach the LLM together with their anchor text.
+func extractTextFromHTML(htmlContent string) string {
+	text, _ := convertHTML(htmlContent)
+	return text
+}
 
-	for {
-		part, err := mr.NextPart()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return "", err
-		}
+// convertHTML converts an HTML document to plain text like extractTextFromHTML,
+// and collects an inventory of its links along the way.
+func convertHTML(htmlContent string) (string, []Link) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return htmlContent, nil
+	}
 
-		func() {
-			defer func() {
-				_ = part.Close() //nolint:errcheck // Best effort close, no action to take on error
-			}()
+	var c htmlConverter
+	c.walk(doc)
 
-			contentType := part.Header.Get("Content-Type")
-			mediaType, _, err := mime.ParseMediaType(contentType)
-			if err != nil {
-				// Skip parts with invalid content type
-				return
-			}
+	return c.text.String(), c.links
+}
+
+// htmlConverter accumulates the text and the links of an HTML document.
+type htmlConverter struct {
+	text    strings.Builder
+	links   []Link
+	heading string
+	// plainLinks renders anchors as their bare text, for headings and anchor texts
+	plainLinks bool
+}
+
+func (c *htmlConverter) walk(n *html.Node) {
+	if n.Type == html.TextNode {
+		// Trim and skip empty text nodes
+		writeWord(&c.text, strings.TrimSpace(n.Data))
+		return
+	}
 
-			partBody, err := io.ReadAll(part)
-			if err != nil {
+	if n.Type == html.ElementNode {
+		switch {
+		case n.Data == "a" && !c.plainLinks:
+			if href := linkTarget(n); href != "" {
+				text, isImage := anchorText(n)
+				writeWord(&c.text, "["+text+"]("+href+")")
+				c.links = append(c.links, newLink(href, text, c.heading, len(c.links), isImage))
 				return
 			}
+		case isHeading(n.Data):
+			c.heading = innerText(n)
+		}
+	}
 
-			switch mediaType {
-			case "text/plain":
-				plainText = string(partBody)
-			case "text/html":
-				htmlText = string(partBody)
-			}
-		}()
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
 	}
 
-	// Prefer plain text over HTML
-	if plainText != "" {
-		return plainText, nil
+	if n.Type == html.ElementNode && isBlockElement(n.Data) && c.text.Len() > 0 {
+		// Add double newline for better paragraph separation
+		c.text.WriteString("\n\n")
+	}
+}
+
+// writeWord appends s, separated by a space from preceding text on the same line.
+func writeWord(text *strings.Builder, s string) {
+	if s == "" {
+		return
+	}
+	// Add space before text if builder has content and doesn't end with whitespace
+	if text.Len() > 0 {
+		lastChar := text.String()[text.Len()-1]
+		if lastChar != '\n' && lastChar != ' ' {
+			text.WriteString(" ")
+		}
 	}
-	if htmlText != "" {
-		return extractTextFromHTML(htmlText), nil
+	text.WriteString(s)
+}
+
+func isBlockElement(tag string) bool {
+	// Add newline after block-level elements for better structure
+	switch tag {
+	case "p", "div", "br", "h1", "h2", "h3", "h4", "h5", "h6", "li", "tr":
+		return true
 	}
+	return false
+}
 
-	return "", nil
+// linkTarget returns the href of an anchor element, or an empty string if the
+// anchor does not point to a web resource (in-page anchors, mailto:, etc.).
+func linkTarget(n *html.Node) string {
+	href := strings.TrimSpace(attr(n, "href"))
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") ||
+		strings.HasPrefix(lower, "mailto:") ||
+		strings.HasPrefix(lower, "tel:") ||
+		strings.HasPrefix(lower, "javascript:") {
+		return ""
+	}
+	return href
 }
 
-func extractTextFromHTML(htmlContent string) string {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return htmlContent
-	}
-
-	var text strings.Builder
-	var extract func(*html.Node)
-	extract = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			// Trim and skip empty text nodes
-			trimmed := strings.TrimSpace(n.Data)
-			if trimmed != "" {
-				// Add space before text if builder has content and doesn't end with whitespace
-				if text.Len() > 0 {
-					lastChar := text.String()[text.Len()-1]
-					if lastChar != '\n' && lastChar != ' ' {
-						text.WriteString(" ")
-					}
-				}
-				text.WriteString(trimmed)
-			}
+func isHeading(tag string) bool {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	}
+	return false
+}
+
+// anchorText returns the visible text of an anchor collapsed onto a single
+// line. Image-only links fall back to the image's alt text and are reported
+// as such.
+func anchorText(n *html.Node) (text string, isImage bool) {
+	if s := innerText(n); s != "" {
+		return s, false
+	}
+	if !hasElement(n, "img") {
+		return "", false
+	}
+	return strings.TrimSpace(findAttr(n, "img", "alt")), true
+}
+
+// innerText returns the text content of an element collapsed onto a single line.
+func innerText(n *html.Node) string {
+	c := htmlConverter{plainLinks: true}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+	return strings.Join(strings.Fields(c.text.String()), " ")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
 		}
-		if n.Type == html.ElementNode {
-			// Add newline after block-level elements for better structure
-			isBlockElement := n.Data == "p" || n.Data == "div" || n.Data == "br" ||
-				n.Data == "h1" || n.Data == "h2" || n.Data == "h3" ||
-				n.Data == "h4" || n.Data == "h5" || n.Data == "h6" ||
-				n.Data == "li" || n.Data == "tr"
-
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				extract(c)
-			}
+	}
+	return ""
+}
 
-			if isBlockElement && text.Len() > 0 {
-				// Add double newline for better paragraph separation
-				text.WriteString("\n\n")
-			}
-		} else {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				extract(c)
-			}
+// hasElement reports whether n has a descendant element with the given tag.
+func hasElement(n *html.Node, tag string) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag || hasElement(c, tag) {
+			return true
 		}
 	}
-	extract(doc)
+	return false
+}
 
-	return text.String()
+// findAttr returns the given attribute of the first descendant element with the given tag.
+func findAttr(n *html.Node, tag, key string) string {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return attr(c, key)
+		}
+		if v := findAttr(c, tag, key); v != "" {
+			return v
+		}
+	}
+	return ""
 }
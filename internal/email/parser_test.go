@@ -1,6 +1,8 @@
 package email
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -41,12 +43,40 @@ This is a plain text email body.
 		t.Errorf("Body = %v, should contain 'plain text email body'", email.Body)
 	}
 
+	if len(email.Links) != 0 {
+		t.Errorf("Links = %+v, want none", email.Links)
+	}
+
 	expectedDate := time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*3600))
 	if !email.Date.Equal(expectedDate) {
 		t.Errorf("Date = %v, want %v", email.Date, expectedDate)
 	}
 }
 
+func TestParse_ListIDAndLanguage(t *testing.T) {
+	rawEmail := `From: Redaktion <redaktion@example.de>
+Subject: Wochenrueckblick
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <123456@example.de>
+List-Id: Der Wochenrueckblick <Rueckblick.Example.DE>
+Content-Language: de-DE, en
+
+Body
+`
+
+	email, err := Parse(strings.NewReader(rawEmail))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+
+	if email.ListID != "rueckblick.example.de" {
+		t.Errorf("ListID = %q, want rueckblick.example.de", email.ListID)
+	}
+	if email.Language != "de-DE" {
+		t.Errorf("Language = %q, want de-DE", email.Language)
+	}
+}
+
 func TestParse_HTML(t *testing.T) {
 	rawEmail := `From: sender@example.com
 To: recipient@example.com
@@ -216,3 +246,185 @@ Test body.
 		t.Errorf("FromEmail = %v, want jorg@example.com", email.FromEmail)
 	}
 }
+
+func TestParse_HTMLPreservesLinks(t *testing.T) {
+	rawEmail := `From: sender@example.com
+Subject: Links
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <links@example.com>
+Content-Type: text/html; charset="UTF-8"
+
+<html><body>
+<h2><a href="https://example.com/story">Big   Story</a></h2>
+<p>Read the <a href="https://example.com/more?a=1&amp;b=2">full <b>report</b></a> today.</p>
+<p><a href="https://example.com/img"><img src="x.png" alt="Cover image"></a></p>
+<p><a href="mailto:editor@example.com">Write us</a> or <a href="#top">go up</a>.</p>
+</body></html>
+`
+
+	email, err := Parse(strings.NewReader(rawEmail))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"[Big Story](https://example.com/story)",
+		"Read the [full report](https://example.com/more?a=1&b=2) today.",
+		"[Cover image](https://example.com/img)",
+		"Write us or go up",
+	} {
+		if !strings.Contains(email.Body, want) {
+			t.Errorf("Body = %q, should contain %q", email.Body, want)
+		}
+	}
+
+	if strings.Contains(email.Body, "mailto:") {
+		t.Errorf("Body = %q, should not contain mailto links", email.Body)
+	}
+
+	if len(email.Links) != 3 {
+		t.Fatalf("Links = %+v, want 3 links", email.Links)
+	}
+	if email.Links[0].Heading != "Big Story" || !email.Links[2].IsImage {
+		t.Errorf("Links = %+v, want heading and image flags", email.Links)
+	}
+}
+
+func parseFixture(t *testing.T, name string) *Email {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer func() {
+		_ = f.Close() //nolint:errcheck // Read-only test fixture
+	}()
+
+	email, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Parse(%s) unexpected error: %v", name, err)
+	}
+	return email
+}
+
+func TestParse_Base64HTMLFixture(t *testing.T) {
+	email := parseFixture(t, "base64-html-utf8.eml")
+
+	if email.Subject != "The Weekly Byte — Issue #42" {
+		t.Errorf("Subject = %q, want decoded subject", email.Subject)
+	}
+	for _, want := range []string{
+		"Grüße from the editors! ☕",
+		"[Async Rust, explained](https://example.org/articles/rust-async)",
+	} {
+		if !strings.Contains(email.Body, want) {
+			t.Errorf("Body = %q, should contain %q", email.Body, want)
+		}
+	}
+}
+
+func TestParse_QuotedPrintableLatin1Fixture(t *testing.T) {
+	email := parseFixture(t, "qp-plain-latin1.eml")
+
+	if email.Subject != "Größenordnungen und Übersetzungen" {
+		t.Errorf("Subject = %q, want decoded subject", email.Subject)
+	}
+	if email.FromName != "Fachblatt München" {
+		t.Errorf("FromName = %q, want Fachblatt München", email.FromName)
+	}
+	for _, want := range []string{
+		"heute geht es um Größenordnungen, Übersetzungen und das Ökosystem rund um Go.",
+		"https://example.de/artikel/masseinheiten",
+	} {
+		if !strings.Contains(email.Body, want) {
+			t.Errorf("Body = %q, should contain %q", email.Body, want)
+		}
+	}
+}
+
+func TestParse_MultipartWindows1252Fixture(t *testing.T) {
+	email := parseFixture(t, "multipart-windows1252.eml")
+
+	want := "“Smart quotes” and the euro sign € – as sent by Outlook."
+	if !strings.Contains(email.Body, want) {
+		t.Errorf("Body = %q, should contain %q", email.Body, want)
+	}
+}
+
+func TestParse_Base64ShiftJISFixture(t *testing.T) {
+	email := parseFixture(t, "base64-plain-shiftjis.eml")
+
+	if email.Subject != "今週のニュース" {
+		t.Errorf("Subject = %q, want 今週のニュース", email.Subject)
+	}
+	if !strings.Contains(email.Body, "今週のニュースをお届けします。") {
+		t.Errorf("Body = %q, should contain decoded Japanese text", email.Body)
+	}
+}
+
+func TestParseWithOptions_HTMLFirst(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "nested-mixed-alternative-related.eml"))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer func() {
+		_ = f.Close() //nolint:errcheck // Read-only test fixture
+	}()
+
+	email, err := ParseWithOptions(f, Options{BodyPreference: PreferHTML})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() unexpected error: %v", err)
+	}
+
+	if email.BodyPart != BodyPartHTML {
+		t.Errorf("BodyPart = %q, want %q", email.BodyPart, BodyPartHTML)
+	}
+	if !strings.Contains(email.Body, "[Roundup story](https://example.com/roundup-story)") {
+		t.Errorf("Body = %q, should contain the HTML story link", email.Body)
+	}
+}
+
+func TestParseWithOptions_BodyLinksOfPartSent(t *testing.T) {
+	raw := `From: sender@example.com
+Subject: Alternatives
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="b"
+
+--b
+Content-Type: text/plain; charset=utf-8
+
+Story: https://example.com/plain-link
+
+--b
+Content-Type: text/html; charset=utf-8
+
+<p><a href="https://click.example.net/track?id=1">Story</a></p>
+--b--
+`
+	tests := []struct {
+		pref BodyPreference
+		want []string
+	}{
+		{PreferPlain, []string{"https://example.com/plain-link"}},
+		{PreferHTML, []string{"https://click.example.net/track?id=1"}},
+		{PreferBoth, []string{"https://example.com/plain-link", "https://click.example.net/track?id=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.pref), func(t *testing.T) {
+			email, err := ParseWithOptions(strings.NewReader(raw), Options{BodyPreference: tt.pref})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() unexpected error: %v", err)
+			}
+			var got []string
+			for _, l := range email.BodyLinks {
+				got = append(got, l.URL)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("BodyLinks = %q, want %q", got, tt.want)
+			}
+			if len(email.Links) != 1 || email.Links[0].URL != "https://click.example.net/track?id=1" {
+				t.Errorf("Links = %+v, want the HTML links", email.Links)
+			}
+		})
+	}
+}
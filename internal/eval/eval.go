@@ -0,0 +1,244 @@
+// Package eval measures the extraction quality on a set of fixture emails
+// with known stories, so that prompt and model changes can be compared. A
+// fixture is an email file <name>.eml next to <name>.expected.json, which
+// lists the expected stories and the URLs that must not become stories.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fxnn/news/internal/story"
+)
+
+// ExpectedSuffix is the file name suffix of a fixture's expectations.
+const ExpectedSuffix = ".expected.json"
+
+// Expected describes the stories a fixture email should yield.
+type Expected struct {
+	Stories []ExpectedStory `json:"stories"`
+	// Boilerplate lists URLs of unsubscribe, privacy, imprint and similar
+	// links that must not become stories
+	Boilerplate []string `json:"boilerplate,omitempty"`
+	// Sponsored lists URLs of ads and sponsored items that must not become
+	// stories
+	Sponsored []string `json:"sponsored,omitempty"`
+}
+
+// ExpectedStory is a story the fixture email should yield. Stories are
+// matched by URL; the headline only makes reports readable.
+type ExpectedStory struct {
+	Headline string `json:"headline"`
+	URL      string `json:"url"`
+}
+
+// Fixture is an email with its expected stories.
+type Fixture struct {
+	Name      string
+	EmailPath string
+	Expected  Expected
+}
+
+// LoadFixtures reads the fixtures in dir, sorted by name.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+ExpectedSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures (*%s) found in %s", ExpectedSuffix, dir)
+	}
+	sort.Strings(paths)
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ExpectedSuffix)
+		f := Fixture{Name: name, EmailPath: filepath.Join(dir, name+".eml")}
+
+		data, err := os.ReadFile(path) //nolint:gosec // G304: Path is from the fixtures directory
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", name, err)
+		}
+		if err := json.Unmarshal(data, &f.Expected); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		if _, err := os.Stat(f.EmailPath); err != nil {
+			return nil, fmt.Errorf("fixture %s has no email: %w", name, err)
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// ExtractFunc extracts the stories of the email file at path.
+type ExtractFunc func(path string) ([]story.Story, error)
+
+// Score counts the URLs of extracted and expected stories.
+type Score struct {
+	Expected  int
+	Extracted int
+	// Matched is the number of extracted stories with an expected URL
+	Matched int
+	// Boilerplate and Sponsored count the listed URLs, and the ones that
+	// leaked into the extracted stories
+	Boilerplate       int
+	BoilerplateLeaked int
+	Sponsored         int
+	SponsoredLeaked   int
+}
+
+// Add returns the sum of both scores.
+func (s Score) Add(other Score) Score {
+	return Score{
+		Expected:          s.Expected + other.Expected,
+		Extracted:         s.Extracted + other.Extracted,
+		Matched:           s.Matched + other.Matched,
+		Boilerplate:       s.Boilerplate + other.Boilerplate,
+		BoilerplateLeaked: s.BoilerplateLeaked + other.BoilerplateLeaked,
+		Sponsored:         s.Sponsored + other.Sponsored,
+		SponsoredLeaked:   s.SponsoredLeaked + other.SponsoredLeaked,
+	}
+}
+
+// Precision is the share of extracted stories that were expected; 1 if
+// nothing was extracted.
+func (s Score) Precision() float64 {
+	if s.Extracted == 0 {
+		return 1
+	}
+	return float64(s.Matched) / float64(s.Extracted)
+}
+
+// Recall is the share of expected stories that were extracted; 1 if nothing
+// was expected.
+func (s Score) Recall() float64 {
+	if s.Expected == 0 {
+		return 1
+	}
+	return float64(s.Matched) / float64(s.Expected)
+}
+
+// Result is the outcome of a single fixture.
+type Result struct {
+	Fixture string
+	Score   Score
+	// Missed are the expected stories that were not extracted
+	Missed []ExpectedStory
+	// Unexpected are the extracted stories that were not expected, including
+	// leaked ones
+	Unexpected []story.Story
+	// Err is set if the extraction failed
+	Err error
+}
+
+// Report is the outcome of an evaluation.
+type Report struct {
+	Results []Result
+}
+
+// Total sums the scores of all fixtures that were extracted.
+func (r *Report) Total() Score {
+	var total Score
+	for _, res := range r.Results {
+		if res.Err == nil {
+			total = total.Add(res.Score)
+		}
+	}
+	return total
+}
+
+// Errors counts the fixtures whose extraction failed.
+func (r *Report) Errors() int {
+	var n int
+	for _, res := range r.Results {
+		if res.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Run extracts the stories of each fixture and scores them.
+func Run(fixtures []Fixture, extract ExtractFunc) *Report {
+	report := &Report{}
+	for _, f := range fixtures {
+		stories, err := extract(f.EmailPath)
+		if err != nil {
+			report.Results = append(report.Results, Result{Fixture: f.Name, Err: err})
+			continue
+		}
+		res := score(f.Expected, stories)
+		res.Fixture = f.Name
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// score compares the extracted stories with the expected ones by URL.
+func score(expected Expected, stories []story.Story) Result {
+	res := Result{Score: Score{
+		Expected:    len(expected.Stories),
+		Extracted:   len(stories),
+		Boilerplate: len(expected.Boilerplate),
+		Sponsored:   len(expected.Sponsored),
+	}}
+
+	extracted := make(map[string]bool, len(stories))
+	for _, s := range stories {
+		extracted[normalizeURL(s.URL)] = true
+	}
+
+	wanted := make(map[string]bool, len(expected.Stories))
+	for _, e := range expected.Stories {
+		u := normalizeURL(e.URL)
+		wanted[u] = true
+		if !extracted[u] {
+			res.Missed = append(res.Missed, e)
+		}
+	}
+
+	boilerplate := urlSet(expected.Boilerplate)
+	sponsored := urlSet(expected.Sponsored)
+	for _, s := range stories {
+		u := normalizeURL(s.URL)
+		switch {
+		case wanted[u]:
+			res.Score.Matched++
+			continue
+		case boilerplate[u]:
+			res.Score.BoilerplateLeaked++
+		case sponsored[u]:
+			res.Score.SponsoredLeaked++
+		}
+		res.Unexpected = append(res.Unexpected, s)
+	}
+	return res
+}
+
+func urlSet(urls []string) map[string]bool {
+	set := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		set[normalizeURL(u)] = true
+	}
+	return set
+}
+
+// normalizeURL makes URLs comparable that differ only in case of scheme and
+// host, a trailing slash or a fragment.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	return u.String()
+}
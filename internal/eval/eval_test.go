@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/extractor"
+	"github.com/fxnn/news/internal/llm"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestScore(t *testing.T) {
+	expected := Expected{
+		Stories: []ExpectedStory{
+			{Headline: "Go 1.25", URL: "https://go.dev/blog/go1.25"},
+			{Headline: "SQLite", URL: "https://blog.example.org/sqlite"},
+		},
+		Boilerplate: []string{"https://example.com/unsubscribe"},
+		Sponsored:   []string{"https://sponsor.example/offer"},
+	}
+	stories := []story.Story{
+		{Headline: "Go", URL: "HTTPS://Go.dev/blog/go1.25/"},
+		{Headline: "Unsubscribe", URL: "https://example.com/unsubscribe"},
+		{Headline: "Offer", URL: "https://sponsor.example/offer"},
+		{Headline: "Other", URL: "https://example.com/other"},
+	}
+
+	res := score(expected, stories)
+
+	want := Score{Expected: 2, Extracted: 4, Matched: 1, Boilerplate: 1, BoilerplateLeaked: 1, Sponsored: 1, SponsoredLeaked: 1}
+	if res.Score != want {
+		t.Errorf("score() = %+v, want %+v", res.Score, want)
+	}
+	if got := res.Score.Precision(); got != 0.25 {
+		t.Errorf("Precision() = %v, want 0.25", got)
+	}
+	if got := res.Score.Recall(); got != 0.5 {
+		t.Errorf("Recall() = %v, want 0.5", got)
+	}
+	if len(res.Missed) != 1 || res.Missed[0].Headline != "SQLite" {
+		t.Errorf("Missed = %+v, want the SQLite story", res.Missed)
+	}
+	if len(res.Unexpected) != 3 {
+		t.Errorf("Unexpected = %+v, want 3 stories", res.Unexpected)
+	}
+}
+
+func TestScore_NoStoriesExpected(t *testing.T) {
+	res := score(Expected{}, nil)
+	if res.Score.Precision() != 1 || res.Score.Recall() != 1 {
+		t.Errorf("Precision(), Recall() = %v, %v, want 1, 1", res.Score.Precision(), res.Score.Recall())
+	}
+}
+
+func TestRun_ReportsFailedFixtures(t *testing.T) {
+	fixtures := []Fixture{
+		{Name: "ok", EmailPath: "ok.eml", Expected: Expected{Stories: []ExpectedStory{{URL: "https://example.com/a"}}}},
+		{Name: "broken", EmailPath: "broken.eml"},
+	}
+	report := Run(fixtures, func(path string) ([]story.Story, error) {
+		if path == "broken.eml" {
+			return nil, errors.New("LLM unavailable")
+		}
+		return []story.Story{{URL: "https://example.com/a"}}, nil
+	})
+
+	if report.Errors() != 1 {
+		t.Errorf("Errors() = %d, want 1", report.Errors())
+	}
+	if total := report.Total(); total.Expected != 1 || total.Matched != 1 {
+		t.Errorf("Total() = %+v, want only the extracted fixture", total)
+	}
+
+	var out strings.Builder
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "error: LLM unavailable") {
+		t.Errorf("report does not show the error:\n%s", out.String())
+	}
+}
+
+// TestFixtures_Replay evaluates the extraction of the fixtures in testdata
+// with the recorded model replies. It fails with llm.ErrNotRecorded once the
+// prompt changed; record new replies with `story-extractor eval --record`.
+func TestFixtures_Replay(t *testing.T) {
+	fixtures, err := LoadFixtures("testdata")
+	if err != nil {
+		t.Fatalf("LoadFixtures() unexpected error: %v", err)
+	}
+
+	cfg := &config.StoryExtractor{
+		LLM:            config.LLM{Provider: "openai", Model: "gpt-4o-mini", Tags: config.Tags{Vocabulary: config.DefaultTagVocabulary}},
+		BodyPreference: "plain-first",
+		URLValidation:  "drop",
+		URLs:           config.URLs{Canonicalize: true},
+	}
+	replay, err := llm.NewReplayExtractor(&cfg.LLM, "testdata/recordings")
+	if err != nil {
+		t.Fatalf("NewReplayExtractor() unexpected error: %v", err)
+	}
+	processor := extractor.NewProcessor(cfg, logger.New(false), replay)
+
+	report := Run(fixtures, processor.ExtractFile)
+
+	for _, res := range report.Results {
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.Fixture, res.Err)
+		}
+	}
+	total := report.Total()
+	if total.Precision() < 1 || total.Recall() < 1 {
+		t.Errorf("precision %.2f, recall %.2f, want 1", total.Precision(), total.Recall())
+	}
+	if total.BoilerplateLeaked > 0 || total.SponsoredLeaked > 0 {
+		t.Errorf("leaked %d boilerplate and %d sponsored links, want none", total.BoilerplateLeaked, total.SponsoredLeaked)
+	}
+	if t.Failed() {
+		var out strings.Builder
+		_ = report.Write(&out) //nolint:errcheck // Only for the test log
+		t.Log("\n" + out.String())
+	}
+}
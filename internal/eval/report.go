@@ -0,0 +1,54 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Write prints a table of the scores per fixture and in total, followed by
+// the missed and unexpected stories of each fixture.
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIXTURE\tEXPECTED\tEXTRACTED\tPRECISION\tRECALL\tBOILERPLATE\tSPONSORED")
+	for _, res := range r.Results {
+		if res.Err != nil {
+			fmt.Fprintf(tw, "%s\terror\t\t\t\t\t\n", res.Fixture)
+			continue
+		}
+		writeScore(tw, res.Fixture, res.Score)
+	}
+	writeScore(tw, "TOTAL", r.Total())
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, res := range r.Results {
+		if res.Err == nil && len(res.Missed) == 0 && len(res.Unexpected) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", res.Fixture)
+		if res.Err != nil {
+			fmt.Fprintf(&b, "  error: %v\n", res.Err)
+		}
+		for _, s := range res.Missed {
+			fmt.Fprintf(&b, "  missed:     %s (%s)\n", s.URL, s.Headline)
+		}
+		for _, s := range res.Unexpected {
+			fmt.Fprintf(&b, "  unexpected: %s (%s)\n", s.URL, s.Headline)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeScore writes a table row; leaked URLs are shown as leaked/listed.
+func writeScore(w io.Writer, name string, s Score) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%.1f%%\t%d/%d\t%d/%d\n", name,
+		s.Expected, s.Extracted, 100*s.Precision(), 100*s.Recall(),
+		s.BoilerplateLeaked, s.Boilerplate, s.SponsoredLeaked, s.Sponsored)
+}
@@ -0,0 +1,75 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/fxnn/news/internal/ledger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// DryRun parses the emails that Run would process and builds their prompts,
+// without calling the LLM or writing any files. The Result counts the emails
+// that would be processed and estimates their LLM usage.
+func (p *Processor) DryRun() (*Result, error) {
+	estimator, ok := p.extractor.(story.Estimator)
+	if !ok {
+		return nil, fmt.Errorf("extractor cannot estimate its usage")
+	}
+
+	return p.run(ledgerPath(p.cfg), ledger.ReadPath, func(log *slog.Logger, index int, path string) (outcome, error) {
+		_, parsedEmail, err := p.readEmail(path)
+		if err != nil {
+			return outcome{}, err
+		}
+
+		if p.alreadyProcessed(log, parsedEmail) {
+			return outcome{}, errSkipped
+		}
+
+		est := estimator.Estimate(parsedEmail)
+		log.Info("would extract stories",
+			"subject", parsedEmail.Subject,
+			"from_email", parsedEmail.FromEmail,
+			"body_length", len(parsedEmail.Body),
+			"requests", est.Requests,
+			"prompt_tokens", est.PromptTokens)
+
+		return outcome{estimate: est}, nil
+	})
+}
+
+// Preview extracts the stories of a single email file and writes them to w
+// as JSON, without touching the storydir.
+func (p *Processor) Preview(path string, w io.Writer) error {
+	stories, err := p.ExtractFile(path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	for _, s := range stories {
+		if err := enc.Encode(s); err != nil {
+			return fmt.Errorf("failed to write story: %w", err)
+		}
+	}
+	return nil
+}
+
+// ExtractFile extracts the stories of a single email file, checking and
+// canonicalizing their URLs as Run does, but without saving them or
+// consulting the ledger.
+func (p *Processor) ExtractFile(path string) ([]story.Story, error) {
+	log := p.log.With("path", path)
+
+	_, parsedEmail, err := p.readEmail(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stories, _, err := p.extractStories(log, 0, parsedEmail)
+	return stories, err
+}
@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// estimatingExtractor estimates one request per email and fails extractions.
+type estimatingExtractor struct {
+	failingExtractor
+}
+
+func (e *estimatingExtractor) Estimate(emailData *email.Email) story.Estimate {
+	return story.Estimate{Requests: 1, PromptTokens: len(emailData.Body), MaxOutputTokens: 100}
+}
+
+func TestProcessor_DryRun_EstimatesWithoutWriting(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		content := "From: News <news@example.com>\nSubject: Weekly\nDate: Mon, 02 Jan 2006 15:04:05 -0700\n" +
+			"Message-ID: <" + id + "@example.com>\n\nNewsletter body.\n"
+		if err := os.WriteFile(filepath.Join(curDir, id+".eml"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Email b was processed before the ledger existed
+	existing := []story.Story{{Headline: "Old", URL: "https://example.com/old"}}
+	parsed, err := email.Parse(strings.NewReader("Date: Mon, 02 Jan 2006 15:04:05 -0700\nMessage-ID: <b@example.com>\n\nx\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := story.WriteStoriesToDir(tmpStorydir, parsed.MessageID, parsed.Date, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{Maildir: tmpMaildir, Storydir: tmpStorydir}
+	extractor := &estimatingExtractor{}
+	result, err := NewProcessor(cfg, logger.New(false), extractor).DryRun()
+	if err != nil {
+		t.Fatalf("DryRun() unexpected error: %v", err)
+	}
+
+	if result.Processed != 1 || result.Skipped != 1 || result.Errors != 0 {
+		t.Errorf("Processed = %d, Skipped = %d, Errors = %d, want 1, 1 and 0", result.Processed, result.Skipped, result.Errors)
+	}
+	if est := result.Estimate; est.Requests != 1 || est.MaxOutputTokens != 100 || est.PromptTokens == 0 {
+		t.Errorf("Estimate = %+v, want the estimate of one email", est)
+	}
+	if extractor.calls != 0 {
+		t.Errorf("extractor called %d times, want 0", extractor.calls)
+	}
+
+	entries, err := os.ReadDir(tmpStorydir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("storydir has %d files, want only the existing story", len(entries))
+	}
+}
+
+func TestProcessor_DryRun_RequiresEstimator(t *testing.T) {
+	cfg := &config.StoryExtractor{Maildir: t.TempDir(), Storydir: t.TempDir()}
+
+	_, err := NewProcessor(cfg, logger.New(false), &story.StubExtractor{}).DryRun()
+	if err == nil {
+		t.Error("DryRun() without estimator expected error, got nil")
+	}
+}
+
+func TestProcessor_Preview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message.eml")
+	content := `From: News <news@example.com>
+Subject: Weekly
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <preview@example.com>
+
+Read https://example.com/story
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// No storydir, so nothing can be written
+	cfg := &config.StoryExtractor{URLValidation: "drop"}
+	extractor := &story.StubExtractor{Stories: []story.ExtractedStory{
+		{Headline: "Story", Teaser: "Teaser", URL: "https://example.com/story"},
+		{Headline: "Made up", Teaser: "Teaser", URL: "https://example.com/made-up"},
+	}}
+
+	var out strings.Builder
+	if err := NewProcessor(cfg, logger.New(false), extractor).Preview(path, &out); err != nil {
+		t.Fatalf("Preview() unexpected error: %v", err)
+	}
+
+	var got story.Story
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("failed to decode story: %v\n%s", err, out.String())
+	}
+	if got.Headline != "Story" || got.FromEmail != "news@example.com" {
+		t.Errorf("story = %+v, want the extracted story", got)
+	}
+	if dec.More() {
+		t.Errorf("Preview() printed stories with URLs not in the email:\n%s", out.String())
+	}
+}
@@ -0,0 +1,110 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/fxnn/news/internal/budget"
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/ledger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// Output modes for config.StoryExtractor.Output.
+const (
+	OutputDir    = "dir"
+	OutputStdout = "stdout"
+	OutputBoth   = "both"
+)
+
+// ParseOutput validates an output mode from configuration. An empty string
+// selects OutputDir if a storydir is set, OutputStdout otherwise.
+func ParseOutput(s, storydir string) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(s))
+	switch mode {
+	case "":
+		if storydir == "" {
+			return OutputStdout, nil
+		}
+		return OutputDir, nil
+	case OutputDir, OutputBoth:
+		if storydir == "" {
+			return "", fmt.Errorf("storydir is required for output %q", mode)
+		}
+		return mode, nil
+	case OutputStdout:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown output %q (want %s, %s or %s)", s, OutputDir, OutputStdout, OutputBoth)
+	}
+}
+
+// newSink creates the sink for the configured output, writing JSON Lines
+// to stdout.
+func newSink(cfg *config.StoryExtractor, stdout io.Writer) story.Sink {
+	mode, err := ParseOutput(cfg.Output, cfg.Storydir)
+	if err != nil {
+		// Validated by the caller; fall back to the directory as before
+		mode = OutputDir
+	}
+
+	switch mode {
+	case OutputStdout:
+		return story.NewJSONLinesSink(stdout)
+	case OutputBoth:
+		return story.MultiSink{story.DirSink{Dir: cfg.Storydir}, story.NewJSONLinesSink(stdout)}
+	default:
+		return story.DirSink{Dir: cfg.Storydir}
+	}
+}
+
+// writesStorydir reports whether the configured output writes story files
+// to the storydir.
+func writesStorydir(cfg *config.StoryExtractor) bool {
+	mode, err := ParseOutput(cfg.Output, cfg.Storydir)
+	if err != nil {
+		// Validated by the caller; newSink falls back to the directory
+		return cfg.Storydir != ""
+	}
+	return mode != OutputStdout
+}
+
+// ledgerPath returns the path of the configured ledger file, or "" for none.
+// The ledger defaults to the storydir only if stories are written there, so
+// that emails printed to stdout are not skipped by later runs writing to the
+// storydir.
+func ledgerPath(cfg *config.StoryExtractor) string {
+	switch {
+	case cfg.Ledger != "":
+		return cfg.Ledger
+	case writesStorydir(cfg):
+		return filepath.Join(cfg.Storydir, ledger.Filename)
+	default:
+		return ""
+	}
+}
+
+// reprocessLedgerPath returns the path of the ledger for reprocessing,
+// which always replaces the story files in the storydir, so that its
+// ledger applies whatever the output.
+func reprocessLedgerPath(cfg *config.StoryExtractor) string {
+	if cfg.Ledger != "" {
+		return cfg.Ledger
+	}
+	return filepath.Join(cfg.Storydir, ledger.Filename)
+}
+
+// budgetPath returns the path of the budget state file, or "" to keep the
+// spend in memory.
+func budgetPath(cfg *config.StoryExtractor) string {
+	switch {
+	case cfg.BudgetState != "":
+		return cfg.BudgetState
+	case cfg.Storydir != "":
+		return filepath.Join(cfg.Storydir, budget.StateFilename)
+	default:
+		return ""
+	}
+}
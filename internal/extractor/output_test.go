@@ -0,0 +1,55 @@
+package extractor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/ledger"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		output, storydir string
+		want             string
+	}{
+		{"", "/stories", OutputDir},
+		{"", "", OutputStdout},
+		{"Both", "/stories", OutputBoth},
+		{"stdout", "/stories", OutputStdout},
+	}
+	for _, tt := range tests {
+		got, err := ParseOutput(tt.output, tt.storydir)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseOutput(%q, %q) = (%q, %v), want %q", tt.output, tt.storydir, got, err, tt.want)
+		}
+	}
+	if _, err := ParseOutput("dir", ""); err == nil {
+		t.Error("ParseOutput(dir) without storydir should return an error")
+	}
+	if _, err := ParseOutput("file", "/stories"); err == nil {
+		t.Error("ParseOutput(file) should return an error")
+	}
+}
+
+func TestLedgerPath(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.StoryExtractor
+		want string
+	}{
+		{"storydir", config.StoryExtractor{Storydir: "/stories"}, filepath.Join("/stories", ledger.Filename)},
+		{"both", config.StoryExtractor{Storydir: "/stories", Output: OutputBoth}, filepath.Join("/stories", ledger.Filename)},
+		// Nothing is written to the storydir, so later dir runs must not skip the emails
+		{"stdout with storydir", config.StoryExtractor{Storydir: "/stories", Output: OutputStdout}, ""},
+		{"stdout", config.StoryExtractor{}, ""},
+		{"configured", config.StoryExtractor{Storydir: "/stories", Output: OutputStdout, Ledger: "/l.jsonl"}, "/l.jsonl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ledgerPath(&tt.cfg); got != tt.want {
+				t.Errorf("ledgerPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package extractor
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/fxnn/news/internal/budget"
 	"github.com/fxnn/news/internal/config"
 	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/ledger"
+	"github.com/fxnn/news/internal/llm"
 	"github.com/fxnn/news/internal/maildir"
 	"github.com/fxnn/news/internal/story"
+	"github.com/fxnn/news/internal/urlcanon"
 )
 
 // Processor orchestrates the story extraction workflow
@@ -18,6 +27,15 @@ type Processor struct {
 	cfg       *config.StoryExtractor
 	log       *slog.Logger
 	extractor story.Extractor
+	canon     *urlcanon.Canonicalizer
+	sink      story.Sink
+	// price of the configured model; priced is false if unknown
+	price  llm.Price
+	priced bool
+	// ledger is open while Run is running; nil without a ledger
+	ledger *ledger.Ledger
+	// budget tracks the spend while Run is running; nil without limits
+	budget *budget.Budget
 }
 
 // Result holds the processing results
@@ -26,19 +44,107 @@ type Result struct {
 	Processed int
 	Skipped   int
 	Errors    int
+	// URLsFixed counts story URLs replaced by the matching link from the email
+	URLsFixed int
+	// URLsUnmatched counts story URLs not found in the email (dropped or flagged)
+	URLsUnmatched int
+	// Salvaged counts processed emails whose stories could only be recovered
+	// partially from a broken model reply
+	Salvaged int
+	// Deferred counts emails left for the next run, since the budget was exceeded
+	Deferred int
+	// budgetErr tells which limit was exceeded
+	budgetErr error
+	// Estimate sums the expected LLM usage of the emails a dry run would process
+	Estimate story.Estimate
+	// Usage and Cost (in USD) sum the LLM usage of all emails, including failed ones
+	Usage story.Usage
+	Cost  float64
+	// Senders breaks the usage down by newsletter sender
+	Senders map[string]SenderUsage
+}
+
+// outcome summarizes the processing of a single email
+type outcome struct {
+	urls     urlStats
+	salvaged bool
+	estimate story.Estimate
+	sender   string
+	usage    story.Usage
+	cost     float64
+}
+
+// record adds the outcome of processing an email to the result.
+func (r *Result) record(log *slog.Logger, o outcome, err error) {
+	if o.usage != (story.Usage{}) {
+		r.addUsage(o.sender, o.usage, o.cost)
+	}
+
+	switch {
+	case errors.Is(err, errSkipped):
+		r.Skipped++
+		return
+	case errors.Is(err, budget.ErrExceeded):
+		r.Deferred++
+		if r.budgetErr == nil {
+			r.budgetErr = err
+		}
+		return
+	case err != nil:
+		log.Warn("failed to process email", "error", err)
+		r.Errors++
+		return
+	}
+
+	r.Processed++
+	r.URLsFixed += o.urls.fixed
+	r.URLsUnmatched += o.urls.unmatched
+	if o.salvaged {
+		r.Salvaged++
+	}
+	r.Estimate = r.Estimate.Add(o.estimate)
 }
 
 // NewProcessor creates a new story extraction processor
 func NewProcessor(cfg *config.StoryExtractor, log *slog.Logger, extractor story.Extractor) *Processor {
+	var canon *urlcanon.Canonicalizer
+	if cfg.URLs.Canonicalize {
+		var resolver urlcanon.Resolver
+		if cfg.URLs.ResolveRedirects {
+			resolver = urlcanon.NewHTTPResolver(cfg.URLs.ResolveTimeout)
+		}
+		canon = urlcanon.New(resolver)
+	}
+
+	price, priced := llm.PriceOf(&cfg.LLM)
+
 	return &Processor{
 		cfg:       cfg,
 		log:       log,
 		extractor: extractor,
+		canon:     canon,
+		sink:      newSink(cfg, os.Stdout),
+		price:     price,
+		priced:    priced,
 	}
 }
 
-// Run executes the story extraction workflow
+// Run executes the story extraction workflow. Once the budget is exceeded,
+// the remaining emails are left for the next run, and the result is returned
+// with an error wrapping budget.ErrExceeded.
 func (p *Processor) Run() (*Result, error) {
+	return p.run(ledgerPath(p.cfg), ledger.OpenPath, p.processEmail)
+}
+
+// emailFunc processes the email at path, logging to log.
+type emailFunc func(log *slog.Logger, index int, path string) (outcome, error)
+
+// ledgerFunc opens the ledger file at path.
+type ledgerFunc func(path string) (*ledger.Ledger, error)
+
+// run applies process to the emails in the Maildir in a bounded worker pool,
+// with the ledger at ledgerPath, if any, opened by openLedger.
+func (p *Processor) run(ledgerPath string, openLedger ledgerFunc, process emailFunc) (*Result, error) {
 	// Read all email files from the Maildir
 	emailPaths, err := maildir.Read(p.cfg.Maildir)
 	if err != nil {
@@ -46,6 +152,30 @@ func (p *Processor) Run() (*Result, error) {
 	}
 
 	p.log.Info("found emails", "count", len(emailPaths))
+	if !p.priced {
+		p.log.Warn("no price known for model, costs are not computed; set input_price and output_price in [[llm.models]]",
+			"model", p.cfg.LLM.Model)
+	}
+
+	if ledgerPath != "" {
+		p.ledger, err = openLedger(ledgerPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		if closeErr := p.ledger.Close(); closeErr != nil {
+			p.log.Warn("failed to close ledger", "error", closeErr)
+		}
+	}()
+
+	limits := budget.Limits{MaxCostPerRun: p.cfg.MaxCostPerRun, MaxTokensPerDay: p.cfg.MaxTokensPerDay}
+	if limits != (budget.Limits{}) {
+		p.budget, err = budget.Open(budgetPath(p.cfg), limits)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Apply limit if specified
 	if p.cfg.Limit > 0 && len(emailPaths) > p.cfg.Limit {
@@ -57,60 +187,142 @@ func (p *Processor) Run() (*Result, error) {
 		Total: len(emailPaths),
 	}
 
-	// Process each email
-	for i, path := range emailPaths {
-		p.log.Debug("processing email", "index", i+1, "path", path)
+	// Process emails in a bounded worker pool; mu guards result
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	for range max(p.cfg.Concurrency, 1) {
+		wg.Go(func() {
+			for i := range indexes {
+				path := emailPaths[i]
+				log := p.log.With("path", path)
+				log.Debug("processing email", "index", i+1)
 
-		if err := p.processEmail(i, path); err != nil {
-			if errors.Is(err, errSkipped) {
-				result.Skipped++
-			} else {
-				p.log.Warn("failed to process email", "path", path, "error", err)
-				result.Errors++
-			}
-			continue
-		}
+				o, err := process(log, i, path)
 
-		result.Processed++
+				mu.Lock()
+				result.record(log, o, err)
+				mu.Unlock()
+			}
+		})
+	}
+	for i := range emailPaths {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
 
 	p.log.Info("processing complete",
 		"total", result.Total,
 		"processed", result.Processed,
 		"skipped", result.Skipped,
-		"errors", result.Errors)
+		"errors", result.Errors,
+		"salvaged", result.Salvaged,
+		"deferred", result.Deferred,
+		"urls_fixed", result.URLsFixed,
+		"urls_unmatched", result.URLsUnmatched,
+		"prompt_tokens", result.Usage.PromptTokens,
+		"completion_tokens", result.Usage.CompletionTokens,
+		"reasoning_tokens", result.Usage.ReasoningTokens,
+		"cost_usd", result.Cost)
+	p.logSenderUsage(result)
 
+	if result.Deferred > 0 {
+		return result, fmt.Errorf("%w, %d emails left for the next run", result.budgetErr, result.Deferred)
+	}
 	return result, nil
 }
 
 var errSkipped = fmt.Errorf("email skipped")
 
-func (p *Processor) processEmail(index int, path string) error {
-	// Open and parse email
-	file, err := os.Open(path) //nolint:gosec // G304: Path is from maildir reader, validated by caller
+// processEmail extracts and saves the stories of one email. log is
+// attributed to the email, since emails are processed concurrently.
+func (p *Processor) processEmail(log *slog.Logger, index int, path string) (outcome, error) {
+	data, parsedEmail, err := p.readEmail(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return outcome{}, err
+	}
+
+	// Check if the email was processed before (incremental processing)
+	if p.alreadyProcessed(log, parsedEmail) {
+		return outcome{}, errSkipped
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			p.log.Warn("failed to close file", "path", path, "error", closeErr)
-		}
-	}()
 
-	parsedEmail, err := email.Parse(file)
+	return p.extractEmail(log, index, path, data, parsedEmail, p.sink.WriteStories)
+}
+
+func (p *Processor) readEmail(path string) ([]byte, *email.Email, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is from maildir reader, validated by caller
 	if err != nil {
-		return fmt.Errorf("failed to parse email: %w", err)
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Check if stories already exist (incremental processing)
-	exists, err := story.StoriesExist(p.cfg.Storydir, parsedEmail.MessageID, parsedEmail.Date)
+	pref, err := email.ParseBodyPreference(p.cfg.BodyPreference)
+	if err != nil {
+		// Validated by the caller; fall back to the default
+		pref = email.PreferPlain
+	}
+	parsedEmail, err := email.ParseWithOptions(bytes.NewReader(data), email.Options{BodyPreference: pref})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	return data, parsedEmail, nil
+}
+
+// saveFunc saves the stories of an email.
+type saveFunc func(messageID string, date time.Time, stories []story.Story) error
+
+// extractEmail extracts the stories of a parsed email, saves them with save
+// and records the outcome in the ledger.
+func (p *Processor) extractEmail(log *slog.Logger, index int, path string, data []byte, parsedEmail *email.Email, save saveFunc) (outcome, error) {
+	// Leave the email for the next run once the budget is used up,
+	// counting what the extractions in progress are expected to spend
+	reservation, err := p.budget.Reserve(p.estimateSpend(parsedEmail))
+	if err != nil {
+		return outcome{}, err
+	}
+
+	hash := sha256.Sum256(data)
+	entry := ledger.Entry{
+		MessageID:   parsedEmail.MessageID,
+		Path:        path,
+		ContentHash: hex.EncodeToString(hash[:]),
+		Provider:    p.cfg.LLM.Provider,
+		Model:       p.cfg.LLM.Model,
+	}
+	if v, ok := p.extractor.(story.PromptVersioner); ok {
+		entry.PromptVersion = v.PromptVersion(parsedEmail)
+	}
+
+	stories, o, err := p.extractStories(log, index, parsedEmail)
+	if budgetErr := p.budget.Settle(reservation, o.usage.PromptTokens+o.usage.CompletionTokens, o.cost); budgetErr != nil {
+		log.Warn("failed to save budget state", "error", budgetErr)
+	}
+	entry.PromptTokens = o.usage.PromptTokens
+	entry.CompletionTokens = o.usage.CompletionTokens
+	entry.ReasoningTokens = o.usage.ReasoningTokens
+	entry.Cost = o.cost
+	if err != nil {
+		p.record(log, entry.Failed(err))
+		return o, err
+	}
+
+	// Save stories to the storydir or stdout
+	err = save(parsedEmail.MessageID, parsedEmail.Date, stories)
 	if err != nil {
-		p.log.Warn("failed to check for existing stories", "path", path, "error", err)
-	} else if exists {
-		p.log.Debug("skipping email (stories already exist)", "path", path, "message_id", parsedEmail.MessageID)
-		return errSkipped
+		p.record(log, entry.Failed(err))
+		return o, fmt.Errorf("failed to write stories: %w", err)
 	}
 
+	p.record(log, entry.Succeeded(len(stories), o.salvaged))
+	return o, nil
+}
+
+// extractStories extracts the stories of a parsed email using the LLM and
+// checks and canonicalizes their URLs.
+func (p *Processor) extractStories(log *slog.Logger, index int, parsedEmail *email.Email) ([]story.Story, outcome, error) {
+	o := outcome{sender: senderOf(parsedEmail)}
 	// Log email details if requested
 	if p.cfg.LogHeaders || p.cfg.LogBodies {
 		logArgs := []any{
@@ -120,6 +332,7 @@ func (p *Processor) processEmail(index int, path string) error {
 			"from_name", parsedEmail.FromName,
 			"date", parsedEmail.Date.Format("2006-01-02 15:04:05"),
 			"message_id", parsedEmail.MessageID,
+			"body_part", parsedEmail.BodyPart,
 			"body_length", len(parsedEmail.Body),
 		}
 
@@ -127,24 +340,42 @@ func (p *Processor) processEmail(index int, path string) error {
 			logArgs = append(logArgs, "body", parsedEmail.Body)
 		}
 
-		p.log.Debug("parsed email", logArgs...)
+		log.Debug("parsed email", logArgs...)
 	}
 
 	// Extract stories using LLM
 	startTime := time.Now()
-	stories, err := p.extractor.Extract(parsedEmail)
+	stories, usage, err := story.ExtractWithUsage(p.extractor, parsedEmail)
 	duration := time.Since(startTime)
+	o.usage, o.cost = usage, p.cost(usage)
 
-	if err != nil {
-		return fmt.Errorf("failed to extract stories: %w", err)
+	switch {
+	case errors.Is(err, story.ErrIncomplete):
+		// Keep what could be recovered, retrying would most likely fail the same way
+		log.Warn("saving partially extracted stories", "error", err)
+		o.salvaged = true
+	case err != nil:
+		return nil, o, fmt.Errorf("failed to extract stories: %w", err)
 	}
 
-	p.log.Info("extracted stories", "path", path, "count", len(stories), "duration_ms", duration.Milliseconds())
+	stories, o.urls = validateURLs(stories, parsedEmail.BodyLinks, p.cfg.URLValidation)
+	p.canonicalizeURLs(stories)
+
+	log.Info("extracted stories",
+		"count", len(stories),
+		"body_part", parsedEmail.BodyPart,
+		"urls_exact", o.urls.exact,
+		"urls_fixed", o.urls.fixed,
+		"urls_unmatched", o.urls.unmatched,
+		"prompt_tokens", o.usage.PromptTokens,
+		"completion_tokens", o.usage.CompletionTokens,
+		"cost_usd", o.cost,
+		"duration_ms", duration.Milliseconds())
 
 	// Log stories if requested
 	if p.cfg.LogStories {
 		for i, s := range stories {
-			p.log.Debug("story",
+			log.Debug("story",
 				"index", i+1,
 				"headline", s.Headline,
 				"teaser", s.Teaser,
@@ -152,11 +383,50 @@ func (p *Processor) processEmail(index int, path string) error {
 		}
 	}
 
-	// Save stories to directory
-	err = story.WriteStoriesToDir(p.cfg.Storydir, parsedEmail.MessageID, parsedEmail.Date, stories)
+	return stories, o, nil
+}
+
+// alreadyProcessed reports whether the email was processed successfully
+// before, according to the ledger or, for emails processed before the
+// ledger existed, existing story files.
+func (p *Processor) alreadyProcessed(log *slog.Logger, parsedEmail *email.Email) bool {
+	if e, ok := p.ledger.Lookup(parsedEmail.MessageID); ok {
+		if e.Done() {
+			log.Debug("skipping email (already processed)", "message_id", parsedEmail.MessageID, "outcome", e.Outcome)
+		}
+		return e.Done()
+	}
+	if p.cfg.Storydir == "" {
+		return false
+	}
+
+	exists, err := story.StoriesExist(p.cfg.Storydir, parsedEmail.MessageID, parsedEmail.Date)
 	if err != nil {
-		return fmt.Errorf("failed to write stories: %w", err)
+		log.Warn("failed to check for existing stories", "error", err)
+		return false
+	}
+	if exists {
+		log.Debug("skipping email (stories already exist)", "message_id", parsedEmail.MessageID)
 	}
+	return exists
+}
 
-	return nil
+// record adds an entry to the ledger. Failing to do so only means the email
+// is processed again next time, so it is not an error.
+func (p *Processor) record(log *slog.Logger, e ledger.Entry) {
+	if err := p.ledger.Record(e); err != nil {
+		log.Warn("failed to record email in ledger", "error", err)
+	}
+}
+
+// canonicalizeURLs replaces tracker-wrapped story URLs by their canonical
+// article URL, keeping the URL from the email as OriginalURL.
+func (p *Processor) canonicalizeURLs(stories []story.Story) {
+	if p.canon == nil {
+		return
+	}
+	for i := range stories {
+		stories[i].OriginalURL = stories[i].URL
+		stories[i].URL = p.canon.Canonicalize(context.Background(), stories[i].URL)
+	}
 }
@@ -1,12 +1,21 @@
 package extractor
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/fxnn/news/internal/budget"
 	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/ledger"
 	"github.com/fxnn/news/internal/logger"
 	"github.com/fxnn/news/internal/story"
 )
@@ -379,3 +388,609 @@ This should be skipped.
 		t.Errorf("Errors = %d, want 1", result.Errors)
 	}
 }
+
+func TestProcessor_Run_DropsStoriesWithURLsNotInEmail(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <links123@example.com>
+Content-Type: text/html; charset=utf-8
+
+<p><a href="https://example.com/real-story">Real story</a></p>
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:       tmpMaildir,
+		Storydir:      tmpStorydir,
+		URLValidation: URLValidationDrop,
+	}
+
+	extractor := &story.StubExtractor{
+		Stories: []story.ExtractedStory{
+			{Headline: "Real", Teaser: "Real story", URL: "http://example.com/real-story/"},
+			{Headline: "Invented", Teaser: "Hallucinated", URL: "https://example.com/made-up"},
+		},
+	}
+
+	processor := NewProcessor(cfg, logger.New(false), extractor)
+	result, err := processor.Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if result.URLsFixed != 1 || result.URLsUnmatched != 1 {
+		t.Errorf("URLsFixed = %d, URLsUnmatched = %d, want 1 and 1", result.URLsFixed, result.URLsUnmatched)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tmpStorydir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 story file, got %d", len(matches))
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"url": "https://example.com/real-story"`) {
+		t.Errorf("story file = %s, want URL replaced by the email's link", data)
+	}
+}
+
+func TestProcessor_Run_ValidatesURLsAgainstBodyPartSent(t *testing.T) {
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <parts123@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="b"
+
+--b
+Content-Type: text/plain; charset=utf-8
+
+Story one: https://example.com/story-one-plain-text-link
+
+--b
+Content-Type: text/html; charset=utf-8
+
+<p><a href="https://click.example.net/track?id=1">Story one</a></p>
+--b--
+`
+	tests := []struct {
+		pref string
+		url  string
+	}{
+		{"plain-first", "https://example.com/story-one-plain-text-link"},
+		{"html-first", "https://click.example.net/track?id=1"},
+		{"both", "https://example.com/story-one-plain-text-link"},
+		// Preferences are case insensitive, as in the configuration
+		{"HTML-First", "https://click.example.net/track?id=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pref, func(t *testing.T) {
+			tmpMaildir := t.TempDir()
+			curDir := filepath.Join(tmpMaildir, "cur")
+			if err := os.MkdirAll(curDir, 0o750); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.StoryExtractor{
+				Maildir:        tmpMaildir,
+				Storydir:       t.TempDir(),
+				BodyPreference: tt.pref,
+				URLValidation:  URLValidationDrop,
+			}
+			extractor := &story.StubExtractor{
+				Stories: []story.ExtractedStory{{Headline: "Story one", Teaser: "Article. One.", URL: tt.url}},
+			}
+
+			processor := NewProcessor(cfg, logger.New(false), extractor)
+			result, err := processor.Run()
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+
+			if result.URLsFixed != 0 || result.URLsUnmatched != 0 {
+				t.Errorf("URLsFixed = %d, URLsUnmatched = %d, want the URL found exactly", result.URLsFixed, result.URLsUnmatched)
+			}
+			stories, err := story.ReadStoriesFromDir(cfg.Storydir, "<parts123@example.com>", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stories) != 1 || stories[0].URL != tt.url {
+				t.Errorf("saved stories = %+v, want the story with URL %s", stories, tt.url)
+			}
+		})
+	}
+}
+
+func TestProcessor_Run_CanonicalizesURLs(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <canon123@example.com>
+
+This is a test email body.
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:  tmpMaildir,
+		Storydir: tmpStorydir,
+		URLs:     config.URLs{Canonicalize: true},
+	}
+
+	trackedURL := "https://example.com/story?utm_source=newsletter&utm_medium=email"
+	extractor := &story.StubExtractor{
+		Stories: []story.ExtractedStory{
+			{Headline: "Story", Teaser: "A story", URL: trackedURL},
+		},
+	}
+
+	processor := NewProcessor(cfg, logger.New(false), extractor)
+	if _, err := processor.Run(); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tmpStorydir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 story file, got %d", len(matches))
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s story.Story
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.URL != "https://example.com/story" {
+		t.Errorf("URL = %q, want canonical URL", s.URL)
+	}
+	if s.OriginalURL != trackedURL {
+		t.Errorf("OriginalURL = %q, want %q", s.OriginalURL, trackedURL)
+	}
+}
+
+// incompleteExtractor returns its stories with a story.ErrIncomplete error,
+// like an LLM extractor that salvaged them from a truncated reply.
+type incompleteExtractor struct {
+	story.StubExtractor
+}
+
+func (e *incompleteExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	stories, _ := e.StubExtractor.Extract(emailData)
+	return stories, fmt.Errorf("%w: response truncated", story.ErrIncomplete)
+}
+
+func TestProcessor_Run_SavesSalvagedStories(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <salvaged123@example.com>
+
+Long newsletter body.
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:  tmpMaildir,
+		Storydir: tmpStorydir,
+	}
+
+	extractor := &incompleteExtractor{story.StubExtractor{
+		Stories: []story.ExtractedStory{
+			{Headline: "First", Teaser: "Recovered story", URL: "https://example.com/first"},
+		},
+	}}
+
+	processor := NewProcessor(cfg, logger.New(false), extractor)
+	result, err := processor.Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if result.Processed != 1 || result.Salvaged != 1 || result.Errors != 0 {
+		t.Errorf("Processed = %d, Salvaged = %d, Errors = %d, want 1, 1 and 0",
+			result.Processed, result.Salvaged, result.Errors)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tmpStorydir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Errorf("Expected 1 story file, got %d", len(matches))
+	}
+}
+
+// concurrencyExtractor records how many Extract calls run at the same time.
+type concurrencyExtractor struct {
+	story.StubExtractor
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (e *concurrencyExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	e.mu.Lock()
+	e.running++
+	e.peak = max(e.peak, e.running)
+	e.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+	return e.StubExtractor.Extract(emailData)
+}
+
+func TestProcessor_Run_ProcessesEmailsConcurrently(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	for i := range 8 {
+		emailContent := fmt.Sprintf(`From: Test User <test@example.com>
+Subject: Newsletter %d
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <concurrent%d@example.com>
+
+Newsletter body.
+`, i, i)
+		if err := os.WriteFile(filepath.Join(curDir, fmt.Sprintf("%d.eml", i)), []byte(emailContent), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(curDir, "invalid.eml"), []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:     tmpMaildir,
+		Storydir:    tmpStorydir,
+		Concurrency: 4,
+	}
+
+	extractor := &concurrencyExtractor{StubExtractor: story.StubExtractor{
+		Stories: []story.ExtractedStory{
+			{Headline: "Test Story", Teaser: "A test story", URL: "https://example.com/test"},
+		},
+	}}
+
+	processor := NewProcessor(cfg, logger.New(false), extractor)
+	result, err := processor.Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if result.Total != 9 || result.Processed != 8 || result.Errors != 1 {
+		t.Errorf("Total = %d, Processed = %d, Errors = %d, want 9, 8 and 1", result.Total, result.Processed, result.Errors)
+	}
+	if extractor.peak < 2 || extractor.peak > 4 {
+		t.Errorf("peak concurrent extractions = %d, want between 2 and 4", extractor.peak)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tmpStorydir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 8 {
+		t.Errorf("Expected 8 story files, got %d", len(matches))
+	}
+}
+
+// failingExtractor fails every extraction.
+type failingExtractor struct {
+	calls int
+}
+
+func (e *failingExtractor) Extract(*email.Email) ([]story.Story, error) {
+	e.calls++
+	return nil, errors.New("LLM unavailable")
+}
+
+func TestProcessor_Run_LedgerSkipsEmailsWithoutStories(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emailContent := `From: Shop <deals@example.com>
+Subject: 20% off everything
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <marketing@example.com>
+
+Buy now!
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:  tmpMaildir,
+		Storydir: tmpStorydir,
+		LLM:      config.LLM{Provider: "openai", Model: "gpt-4o-mini"},
+	}
+
+	// First run finds no stories, second run must not ask the LLM again
+	first, err := NewProcessor(cfg, logger.New(false), &story.StubExtractor{}).Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if first.Processed != 1 {
+		t.Errorf("first run Processed = %d, want 1", first.Processed)
+	}
+
+	second, err := NewProcessor(cfg, logger.New(false), &failingExtractor{}).Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if second.Skipped != 1 || second.Errors != 0 {
+		t.Errorf("second run Skipped = %d, Errors = %d, want 1 and 0", second.Skipped, second.Errors)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpStorydir, ledger.Filename))
+	if err != nil {
+		t.Fatalf("failed to read ledger: %v", err)
+	}
+	var entry ledger.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("failed to decode ledger entry: %v", err)
+	}
+	if entry.MessageID != "<marketing@example.com>" || entry.Outcome != ledger.OutcomeNoStories ||
+		entry.Model != "gpt-4o-mini" || len(entry.ContentHash) != 64 || !strings.HasSuffix(entry.Path, "test.eml") {
+		t.Errorf("ledger entry = %+v, want no-stories entry with model, hash and path", entry)
+	}
+}
+
+func TestProcessor_Run_LedgerRetriesFailedEmails(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <retry@example.com>
+
+Newsletter body.
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{Maildir: tmpMaildir, Storydir: tmpStorydir}
+
+	failing := &failingExtractor{}
+	if _, err := NewProcessor(cfg, logger.New(false), failing).Run(); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	result, err := NewProcessor(cfg, logger.New(false), failing).Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if failing.calls != 2 || result.Errors != 1 {
+		t.Errorf("calls = %d, Errors = %d, want failed email retried on the second run", failing.calls, result.Errors)
+	}
+}
+
+func TestProcessor_Run_StdoutWithLedger(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	ledgerPath := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	emailContent := `From: Test User <test@example.com>
+Subject: Test Newsletter
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <stdout@example.com>
+
+Read https://example.com/story
+`
+	if err := os.WriteFile(filepath.Join(curDir, "test.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// No storydir: stories go to stdout, the ledger keeps the run incremental
+	cfg := &config.StoryExtractor{Maildir: tmpMaildir, Ledger: ledgerPath}
+	extractor := &story.StubExtractor{Stories: []story.ExtractedStory{
+		{Headline: "Story", Teaser: "Teaser", URL: "https://example.com/story"},
+	}}
+
+	var out strings.Builder
+	processor := NewProcessor(cfg, logger.New(false), extractor)
+	processor.sink = newSink(cfg, &out)
+	result, err := processor.Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if result.Processed != 1 {
+		t.Errorf("Processed = %d, want 1", result.Processed)
+	}
+
+	var s story.Story
+	if err := json.Unmarshal([]byte(out.String()), &s); err != nil || s.Headline != "Story" {
+		t.Errorf("stdout = %q, want the story as a JSON line (error: %v)", out.String(), err)
+	}
+
+	out.Reset()
+	processor = NewProcessor(cfg, logger.New(false), &failingExtractor{})
+	processor.sink = newSink(cfg, &out)
+	second, err := processor.Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if second.Skipped != 1 || out.Len() != 0 {
+		t.Errorf("second run Skipped = %d, stdout = %q, want the email skipped", second.Skipped, out.String())
+	}
+}
+
+// usageExtractor reports 1000 prompt and 200 completion tokens per email.
+type usageExtractor struct {
+	story.StubExtractor
+}
+
+func (e *usageExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	stories, err := e.Extract(emailData)
+	return stories, story.Usage{PromptTokens: 1000, CompletionTokens: 200}, err
+}
+
+func TestProcessor_Run_AccountsUsageBySender(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	senders := map[string]string{"a": "weekly@example.com", "b": "Weekly@Example.com", "c": "daily@example.org"}
+	for id, sender := range senders {
+		content := fmt.Sprintf("From: News <%s>\nSubject: News\nDate: Mon, 02 Jan 2006 15:04:05 -0700\n"+
+			"Message-ID: <%s@example.com>\n\nNewsletter body.\n", sender, id)
+		if err := os.WriteFile(filepath.Join(curDir, id+".eml"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.StoryExtractor{
+		Maildir:  tmpMaildir,
+		Storydir: tmpStorydir,
+		LLM: config.LLM{Provider: "openai", Model: "priced", Models: []config.Model{
+			{Name: "priced", InputPrice: 1, OutputPrice: 10},
+		}},
+	}
+	result, err := NewProcessor(cfg, logger.New(false), &usageExtractor{}).Run()
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if result.Usage.PromptTokens != 3000 || result.Usage.CompletionTokens != 600 {
+		t.Errorf("Usage = %+v, want the sum of 3 emails", result.Usage)
+	}
+	// 1000 * $1/M + 200 * $10/M = $0.003 per email
+	if math.Abs(result.Cost-0.009) > 1e-9 {
+		t.Errorf("Cost = %v, want 0.009", result.Cost)
+	}
+	weekly := result.Senders["weekly@example.com"]
+	if len(result.Senders) != 2 || weekly.Emails != 2 || math.Abs(weekly.Cost-0.006) > 1e-9 {
+		t.Errorf("Senders = %+v, want 2 emails of weekly@example.com and 1 of daily@example.org", result.Senders)
+	}
+
+	l, err := ledger.Open(tmpStorydir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close() //nolint:errcheck // Test cleanup
+	entry, ok := l.Lookup("<a@example.com>")
+	if !ok || entry.PromptTokens != 1000 || entry.CompletionTokens != 200 || math.Abs(entry.Cost-0.003) > 1e-9 {
+		t.Errorf("ledger entry = %+v, want usage and cost", entry)
+	}
+}
+
+func TestProcessor_Run_StopsAtBudget(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		content := fmt.Sprintf("From: News <news@example.com>\nSubject: News\nDate: Mon, 02 Jan 2006 15:04:05 -0700\n"+
+			"Message-ID: <%s@example.com>\n\nNewsletter body.\n", id)
+		if err := os.WriteFile(filepath.Join(curDir, id+".eml"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each email uses 1200 tokens
+	cfg := &config.StoryExtractor{
+		Maildir:         tmpMaildir,
+		Storydir:        tmpStorydir,
+		Concurrency:     1,
+		MaxTokensPerDay: 2400,
+		LLM:             config.LLM{Provider: "ollama", Model: "llama3.1:8b"},
+	}
+	result, err := NewProcessor(cfg, logger.New(false), &usageExtractor{}).Run()
+	if !errors.Is(err, budget.ErrExceeded) {
+		t.Fatalf("Run() error = %v, want budget exceeded", err)
+	}
+	if result.Processed != 2 || result.Deferred != 1 {
+		t.Errorf("Processed = %d, Deferred = %d, want 2 and 1", result.Processed, result.Deferred)
+	}
+
+	// The daily spend persists, so a second run on the same day defers the rest
+	result, err = NewProcessor(cfg, logger.New(false), &usageExtractor{}).Run()
+	if !errors.Is(err, budget.ErrExceeded) {
+		t.Fatalf("second Run() error = %v, want budget exceeded", err)
+	}
+	if result.Processed != 0 || result.Skipped != 2 || result.Deferred != 1 {
+		t.Errorf("second run Processed = %d, Skipped = %d, Deferred = %d, want 0, 2 and 1",
+			result.Processed, result.Skipped, result.Deferred)
+	}
+
+	// The remaining email is picked up once the limit allows it
+	cfg.MaxTokensPerDay = 0
+	result, err = NewProcessor(cfg, logger.New(false), &usageExtractor{}).Run()
+	if err != nil {
+		t.Fatalf("third Run() unexpected error: %v", err)
+	}
+	if result.Processed != 1 || result.Skipped != 2 {
+		t.Errorf("third run Processed = %d, Skipped = %d, want 1 and 2", result.Processed, result.Skipped)
+	}
+}
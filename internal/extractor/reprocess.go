@@ -0,0 +1,168 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/ledger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// Selector picks the emails to reprocess. An email must match all fields
+// that are set; at least one must be set, or All.
+type Selector struct {
+	// All selects every email
+	All bool
+	// Sender matches a substring of the sender's address or name, ignoring case
+	Sender string
+	// Since and Until restrict the email date to [Since, Until)
+	Since time.Time
+	Until time.Time
+	// MessageIDs selects emails by Message-ID, with or without angle brackets
+	MessageIDs []string
+	// PromptVersion selects emails last extracted with this prompt version
+	PromptVersion string
+	// ZeroStories selects emails whose last extraction yielded no stories
+	ZeroStories bool
+}
+
+// IsEmpty reports whether the selector selects nothing, since neither All
+// nor any criterion is set.
+func (s Selector) IsEmpty() bool {
+	return !s.All && s.Sender == "" && s.Since.IsZero() && s.Until.IsZero() &&
+		len(s.MessageIDs) == 0 && s.PromptVersion == "" && !s.ZeroStories
+}
+
+// Matches reports whether an email is selected. entry is the email's latest
+// ledger entry, if found.
+func (s Selector) Matches(e *email.Email, entry ledger.Entry, found bool) bool {
+	if s.IsEmpty() {
+		return false
+	}
+	if s.Sender != "" {
+		sender := strings.ToLower(s.Sender)
+		if !strings.Contains(strings.ToLower(e.FromEmail), sender) && !strings.Contains(strings.ToLower(e.FromName), sender) {
+			return false
+		}
+	}
+	if !s.Since.IsZero() && e.Date.Before(s.Since) {
+		return false
+	}
+	if !s.Until.IsZero() && !e.Date.Before(s.Until) {
+		return false
+	}
+	if len(s.MessageIDs) > 0 && !containsMessageID(s.MessageIDs, e.MessageID) {
+		return false
+	}
+	if s.PromptVersion != "" && (!found || entry.PromptVersion != s.PromptVersion) {
+		return false
+	}
+	if s.ZeroStories && (!found || entry.Outcome != ledger.OutcomeNoStories) {
+		return false
+	}
+	return true
+}
+
+func containsMessageID(ids []string, messageID string) bool {
+	want := strings.Trim(messageID, "<>")
+	for _, id := range ids {
+		if strings.Trim(strings.TrimSpace(id), "<>") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Reprocess extracts the stories of the selected emails again, whether or
+// not they were processed before, and replaces their story files. The
+// story files are replaced in the storydir, ignoring the configured output,
+// since that is where the stories to replace are. Emails not selected are
+// counted as skipped. If diff is not nil, the changes
+// between old and new stories are written to it.
+func (p *Processor) Reprocess(sel Selector, diff io.Writer) (*Result, error) {
+	if sel.IsEmpty() {
+		return nil, fmt.Errorf("no emails selected for reprocessing")
+	}
+	if p.cfg.Storydir == "" {
+		return nil, fmt.Errorf("storydir is required for reprocessing")
+	}
+
+	var mu sync.Mutex // serializes diff output of concurrent emails
+	return p.run(reprocessLedgerPath(p.cfg), ledger.OpenPath, func(log *slog.Logger, index int, path string) (outcome, error) {
+		data, parsedEmail, err := p.readEmail(path)
+		if err != nil {
+			return outcome{}, err
+		}
+
+		entry, found := p.ledger.Lookup(parsedEmail.MessageID)
+		if !sel.Matches(parsedEmail, entry, found) {
+			return outcome{}, errSkipped
+		}
+
+		replace := func(messageID string, date time.Time, stories []story.Story) error {
+			old, err := story.ReadStoriesFromDir(p.cfg.Storydir, messageID, date)
+			if err != nil {
+				return err
+			}
+			if err := story.ReplaceStoriesInDir(p.cfg.Storydir, messageID, date, stories); err != nil {
+				return err
+			}
+			if diff != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if err := writeDiff(diff, parsedEmail, old, stories); err != nil {
+					log.Warn("failed to write diff", "error", err)
+				}
+			}
+			return nil
+		}
+
+		return p.extractEmail(log, index, path, data, parsedEmail, replace)
+	})
+}
+
+// writeDiff writes the changes between the old and new stories of an email,
+// matched by URL: "-" for removed, "+" for added and "~" for changed stories.
+// The changes are written at once, so that diffs of concurrently processed
+// emails don't interleave.
+func writeDiff(w io.Writer, e *email.Email, old, updated []story.Story) error {
+	oldByURL := make(map[string]story.Story, len(old))
+	for _, s := range old {
+		oldByURL[s.URL] = s
+	}
+	updatedByURL := make(map[string]story.Story, len(updated))
+	for _, s := range updated {
+		updatedByURL[s.URL] = s
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %q\n", e.Date.Format("2006-01-02"), e.MessageID, e.Subject)
+	for _, s := range old {
+		if _, ok := updatedByURL[s.URL]; !ok {
+			fmt.Fprintf(&b, "- %s <%s>\n", s.Headline, s.URL)
+		}
+	}
+	for _, s := range updated {
+		prev, ok := oldByURL[s.URL]
+		switch {
+		case !ok:
+			fmt.Fprintf(&b, "+ %s <%s>\n", s.Headline, s.URL)
+		case prev.Headline != s.Headline || prev.Teaser != s.Teaser:
+			fmt.Fprintf(&b, "~ %s <%s>\n", s.Headline, s.URL)
+			if prev.Headline != s.Headline {
+				fmt.Fprintf(&b, "    headline was: %s\n", prev.Headline)
+			}
+			if prev.Teaser != s.Teaser {
+				fmt.Fprintf(&b, "    teaser was: %s\n", prev.Teaser)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
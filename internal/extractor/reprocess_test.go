@@ -0,0 +1,199 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/ledger"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestSelector_Matches(t *testing.T) {
+	e := &email.Email{
+		FromEmail: "news@Example.com",
+		FromName:  "Example Weekly",
+		Date:      time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC),
+		MessageID: "<abc@example.com>",
+	}
+	noStories := ledger.Entry{Outcome: ledger.OutcomeNoStories, PromptVersion: "default@1"}
+
+	tests := []struct {
+		name  string
+		sel   Selector
+		entry ledger.Entry
+		found bool
+		want  bool
+	}{
+		{"empty selects nothing", Selector{}, noStories, true, false},
+		{"all", Selector{All: true}, ledger.Entry{}, false, true},
+		{"sender address", Selector{Sender: "example.com"}, ledger.Entry{}, false, true},
+		{"sender name", Selector{Sender: "weekly"}, ledger.Entry{}, false, true},
+		{"other sender", Selector{Sender: "other.org"}, ledger.Entry{}, false, false},
+		{"since is inclusive", Selector{Since: time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)}, ledger.Entry{}, false, true},
+		{"until is exclusive", Selector{Until: time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)}, ledger.Entry{}, false, false},
+		{"date range", Selector{Since: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, ledger.Entry{}, false, true},
+		{"message id without brackets", Selector{MessageIDs: []string{"other@x", "abc@example.com"}}, ledger.Entry{}, false, true},
+		{"other message id", Selector{MessageIDs: []string{"<other@x>"}}, ledger.Entry{}, false, false},
+		{"prompt version", Selector{PromptVersion: "default@1"}, noStories, true, true},
+		{"other prompt version", Selector{PromptVersion: "default@2"}, noStories, true, false},
+		{"prompt version without entry", Selector{PromptVersion: "default@1"}, ledger.Entry{}, false, false},
+		{"zero stories", Selector{ZeroStories: true}, noStories, true, true},
+		{"zero stories with stories", Selector{ZeroStories: true}, ledger.Entry{Outcome: ledger.OutcomeStories}, true, false},
+		{"all criteria must match", Selector{Sender: "example.com", ZeroStories: true}, ledger.Entry{Outcome: ledger.OutcomeError}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sel.Matches(e, tt.entry, tt.found); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessor_Reprocess_ReplacesSelectedStories(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	emails := map[string]string{
+		"a.eml": `From: News <news@example.com>
+Subject: Example Weekly
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <a@example.com>
+
+Kept https://example.com/kept
+Changed https://example.com/changed
+Removed https://example.com/removed
+Added https://example.com/added
+`,
+		"b.eml": `From: Other <other@example.org>
+Subject: Other Digest
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <b@example.org>
+
+Kept https://example.com/kept
+`,
+	}
+	for name, content := range emails {
+		if err := os.WriteFile(filepath.Join(curDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.StoryExtractor{Maildir: tmpMaildir, Storydir: tmpStorydir}
+
+	first := &story.StubExtractor{Stories: []story.ExtractedStory{
+		{Headline: "Kept", Teaser: "Same", URL: "https://example.com/kept"},
+		{Headline: "Changed", Teaser: "Old teaser", URL: "https://example.com/changed"},
+		{Headline: "Removed", Teaser: "Gone", URL: "https://example.com/removed"},
+	}}
+	if _, err := NewProcessor(cfg, logger.New(false), first).Run(); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	second := &story.StubExtractor{Stories: []story.ExtractedStory{
+		{Headline: "Kept", Teaser: "Same", URL: "https://example.com/kept"},
+		{Headline: "Changed", Teaser: "New teaser", URL: "https://example.com/changed"},
+		{Headline: "Added", Teaser: "New", URL: "https://example.com/added"},
+	}}
+	var diff strings.Builder
+	result, err := NewProcessor(cfg, logger.New(false), second).Reprocess(Selector{Sender: "news@example.com"}, &diff)
+	if err != nil {
+		t.Fatalf("Reprocess() unexpected error: %v", err)
+	}
+	if result.Processed != 1 || result.Skipped != 1 || result.Errors != 0 {
+		t.Errorf("Processed = %d, Skipped = %d, Errors = %d, want 1, 1 and 0", result.Processed, result.Skipped, result.Errors)
+	}
+
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))
+	got, err := story.ReadStoriesFromDir(tmpStorydir, "<a@example.com>", date)
+	if err != nil {
+		t.Fatalf("ReadStoriesFromDir() unexpected error: %v", err)
+	}
+	if len(got) != 3 || got[1].Teaser != "New teaser" || got[2].Headline != "Added" {
+		t.Errorf("stories = %+v, want the reprocessed stories", got)
+	}
+
+	// The email that wasn't selected keeps its stories
+	other, err := story.ReadStoriesFromDir(tmpStorydir, "<b@example.org>", date)
+	if err != nil {
+		t.Fatalf("ReadStoriesFromDir() unexpected error: %v", err)
+	}
+	if len(other) != 1 {
+		t.Errorf("unselected email has %d stories, want 1", len(other))
+	}
+
+	want := []string{
+		"- Removed <https://example.com/removed>",
+		"~ Changed <https://example.com/changed>",
+		"    teaser was: Old teaser",
+		"+ Added <https://example.com/added>",
+	}
+	for _, line := range want {
+		if !strings.Contains(diff.String(), line+"\n") {
+			t.Errorf("diff does not contain %q:\n%s", line, diff.String())
+		}
+	}
+	if strings.Contains(diff.String(), "Kept") {
+		t.Errorf("diff contains unchanged story:\n%s", diff.String())
+	}
+}
+
+func TestProcessor_Reprocess_RequiresSelector(t *testing.T) {
+	cfg := &config.StoryExtractor{Maildir: t.TempDir(), Storydir: t.TempDir()}
+
+	_, err := NewProcessor(cfg, logger.New(false), &story.StubExtractor{}).Reprocess(Selector{}, nil)
+	if err == nil {
+		t.Error("Reprocess() with empty selector expected error, got nil")
+	}
+}
+
+func TestProcessor_Reprocess_UsesStorydirLedgerWithStdoutOutput(t *testing.T) {
+	tmpMaildir := t.TempDir()
+	tmpStorydir := t.TempDir()
+
+	curDir := filepath.Join(tmpMaildir, "cur")
+	if err := os.MkdirAll(curDir, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	emailContent := `From: News <news@example.com>
+Subject: Example Weekly
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Message-ID: <a@example.com>
+
+Story https://example.com/story
+`
+	if err := os.WriteFile(filepath.Join(curDir, "a.eml"), []byte(emailContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.StoryExtractor{Maildir: tmpMaildir, Storydir: tmpStorydir}
+	if _, err := NewProcessor(cfg, logger.New(false), &story.StubExtractor{}).Run(); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	// Reprocessing replaces the story files in the storydir whatever the
+	// output, so the storydir's ledger selects the emails
+	stdout := *cfg
+	stdout.Output = OutputStdout
+	extractor := &story.StubExtractor{Stories: []story.ExtractedStory{
+		{Headline: "Story", Teaser: "Found", URL: "https://example.com/story"},
+	}}
+	result, err := NewProcessor(&stdout, logger.New(false), extractor).Reprocess(Selector{ZeroStories: true}, nil)
+	if err != nil {
+		t.Fatalf("Reprocess() unexpected error: %v", err)
+	}
+	if result.Processed != 1 || result.Skipped != 0 {
+		t.Errorf("Processed = %d, Skipped = %d, want the email without stories reprocessed", result.Processed, result.Skipped)
+	}
+}
@@ -0,0 +1,258 @@
+package extractor
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+// URL validation modes for config.StoryExtractor.URLValidation.
+const (
+	URLValidationDrop = "drop"
+	URLValidationFlag = "flag"
+	URLValidationOff  = "off"
+)
+
+// ParseURLValidation validates a URL validation mode from configuration.
+// An empty string selects the default, URLValidationDrop.
+func ParseURLValidation(s string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case "":
+		return URLValidationDrop, nil
+	case URLValidationDrop, URLValidationFlag, URLValidationOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown url validation mode %q (want %s, %s or %s)",
+			s, URLValidationDrop, URLValidationFlag, URLValidationOff)
+	}
+}
+
+// urlStats counts the outcome of validating story URLs against email links.
+type urlStats struct {
+	exact     int
+	fixed     int
+	unmatched int
+}
+
+// validateURLs checks each story URL against the links of the email body
+// that was sent to the LLM. Near-misses (different normalization, small typos) are replaced by the
+// email's link; stories whose URL is not in the email are dropped or flagged,
+// depending on mode, which is parsed like the configuration. Near-misses that
+// might point to a different article are flagged in either mode, but not
+// replaced. Emails without
+// any links are left alone, since there is nothing to validate against.
+func validateURLs(stories []story.Story, links []email.Link, mode string) ([]story.Story, urlStats) {
+	var stats urlStats
+	mode, err := ParseURLValidation(mode)
+	if err != nil {
+		// Validated by the caller; fall back to the default
+		mode = URLValidationDrop
+	}
+	if mode == URLValidationOff || len(links) == 0 {
+		return stories, stats
+	}
+
+	index := newLinkIndex(links)
+	validated := make([]story.Story, 0, len(stories))
+	for _, s := range stories {
+		match, kind := index.match(s.URL)
+		switch kind {
+		case matchExact:
+			stats.exact++
+		case matchNormalized, matchFuzzy:
+			stats.fixed++
+			s.URL = match
+		case matchSuspect:
+			stats.unmatched++
+			s.URLUnverified = true
+		case matchNone:
+			stats.unmatched++
+			if mode != URLValidationFlag {
+				continue
+			}
+			s.URLUnverified = true
+		}
+		validated = append(validated, s)
+	}
+
+	return validated, stats
+}
+
+type matchKind int
+
+const (
+	matchNone matchKind = iota
+	matchExact
+	matchNormalized
+	matchFuzzy
+	// matchSuspect is a near-miss that is ambiguous, or differs in digits or
+	// the final path segment, like episode-24 for episode-42, and so might
+	// be a different article
+	matchSuspect
+)
+
+// linkIndex looks up story URLs among the links of an email.
+type linkIndex struct {
+	exact      map[string]bool
+	normalized map[string]string // normalized URL -> original link
+}
+
+func newLinkIndex(links []email.Link) *linkIndex {
+	idx := &linkIndex{
+		exact:      make(map[string]bool, len(links)),
+		normalized: make(map[string]string, len(links)),
+	}
+	for _, l := range links {
+		idx.exact[l.URL] = true
+		n := normalizeURL(l.URL)
+		if _, ok := idx.normalized[n]; !ok {
+			idx.normalized[n] = l.URL
+		}
+	}
+	return idx
+}
+
+// match returns the email link corresponding to storyURL and how it was found.
+func (idx *linkIndex) match(storyURL string) (string, matchKind) {
+	if idx.exact[storyURL] {
+		return storyURL, matchExact
+	}
+
+	n := normalizeURL(storyURL)
+	if link, ok := idx.normalized[n]; ok {
+		return link, matchNormalized
+	}
+
+	// Allow roughly one typo per 20 characters, but at least two
+	maxDistance := max(2, len(n)/20)
+	best, bestDistance, ambiguous := "", maxDistance+1, false
+	for candidate, link := range idx.normalized {
+		d := boundedLevenshtein(n, candidate, maxDistance)
+		switch {
+		case d < bestDistance:
+			best, bestDistance, ambiguous = link, d, false
+		case d == bestDistance && link != best:
+			ambiguous = true
+		}
+	}
+	switch {
+	case best == "":
+		return "", matchNone
+	case ambiguous || !safeRepair(n, normalizeURL(best)):
+		return "", matchSuspect
+	default:
+		return best, matchFuzzy
+	}
+}
+
+// safeRepair reports whether the normalized URLs a and b differ only in
+// characters other than digits, and before the final path segment, so
+// that replacing one by the other fixes a typo rather than pointing to a
+// different article.
+func safeRepair(a, b string) bool {
+	prefix := 0
+	for prefix < min(len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < min(len(a), len(b))-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, u := range []string{a, b} {
+		diff := u[prefix : len(u)-suffix]
+		if strings.ContainsAny(diff, "0123456789") || len(u)-suffix > finalSegmentStart(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// finalSegmentStart returns the index at which the final path segment of
+// the normalized URL u starts; the query counts as part of it.
+func finalSegmentStart(u string) int {
+	end := len(u)
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		end = i
+	}
+	start := 0
+	if i := strings.Index(u, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.LastIndexByte(u[start:end], '/'); i >= 0 {
+		return start + i + 1
+	}
+	return end
+}
+
+// normalizeURL reduces a URL to a form in which trivially different spellings
+// compare equal: HTML entities decoded, scheme and host lowercased, "www."
+// and fragment dropped, trailing slash removed, http upgraded to https.
+func normalizeURL(raw string) string {
+	s := strings.TrimSpace(html.UnescapeString(raw))
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(strings.ToLower(s), "/")
+	}
+
+	u.Scheme = "https"
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+
+	return u.String()
+}
+
+// boundedLevenshtein returns the edit distance between a and b, or
+// maxDistance+1 if it exceeds maxDistance. Only a diagonal band of width 2*max+1 is computed, which
+// keeps comparisons of long tracking URLs cheap.
+func boundedLevenshtein(a, b string, maxDistance int) int {
+	if abs(len(a)-len(b)) > maxDistance {
+		return maxDistance + 1
+	}
+
+	const inf = 1 << 30
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		lo, hi := max(1, i-maxDistance), min(len(b), i+maxDistance)
+		for j := range curr {
+			curr[j] = inf
+		}
+		if i <= maxDistance {
+			curr[0] = i
+		}
+		rowMin := curr[0]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDistance {
+			return maxDistance + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return min(prev[len(b)], maxDistance+1)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
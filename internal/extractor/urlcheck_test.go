@@ -0,0 +1,139 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestParseURLValidation(t *testing.T) {
+	for input, want := range map[string]string{"": URLValidationDrop, "Flag": URLValidationFlag, "off": URLValidationOff} {
+		got, err := ParseURLValidation(input)
+		if err != nil || got != want {
+			t.Errorf("ParseURLValidation(%q) = (%q, %v), want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseURLValidation("fix"); err == nil {
+		t.Error("ParseURLValidation(fix) should return an error")
+	}
+}
+
+func TestValidateURLs(t *testing.T) {
+	links := []email.Link{
+		{URL: "https://example.com/articles/async-rust-explained"},
+		{URL: "https://www.example.org/post?id=1&ref=mail"},
+		{URL: "https://example.net/podcast/episode-42"},
+	}
+	stories := []story.Story{
+		{Headline: "Exact", URL: "https://example.com/articles/async-rust-explained"},
+		{Headline: "Normalized", URL: "http://example.org/post?id=1&amp;ref=mail#top"},
+		{Headline: "Typo", URL: "https://exmaple.com/articles/async-rust-explained"},
+		{Headline: "Other episode", URL: "https://example.net/podcast/episode-24"},
+		{Headline: "Invented", URL: "https://made-up.example/story"},
+	}
+
+	validated, stats := validateURLs(stories, links, URLValidationDrop)
+
+	if stats.exact != 1 || stats.fixed != 2 || stats.unmatched != 2 {
+		t.Errorf("stats = %+v, want 1 exact, 2 fixed, 2 unmatched", stats)
+	}
+	if len(validated) != 4 {
+		t.Fatalf("validated = %+v, want 4 stories", validated)
+	}
+	if validated[1].URL != "https://www.example.org/post?id=1&ref=mail" {
+		t.Errorf("normalized URL = %q, want the email's link", validated[1].URL)
+	}
+	if validated[2].URL != "https://example.com/articles/async-rust-explained" {
+		t.Errorf("fuzzy URL = %q, want the email's link", validated[2].URL)
+	}
+	// A different number might be a different episode, so it is flagged
+	// rather than replaced, even when dropping
+	if validated[3].URL != "https://example.net/podcast/episode-24" || !validated[3].URLUnverified {
+		t.Errorf("near-miss = %+v, want it flagged and unchanged", validated[3])
+	}
+}
+
+func TestValidateURLs_FlagsRiskyRepairs(t *testing.T) {
+	links := []email.Link{
+		{URL: "https://example.com/articles/async-rust-explained"},
+		{URL: "https://example.com/2024/05/go-release"},
+	}
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"final segment", "https://example.com/articles/async-rust-explaned"},
+		{"digits", "https://example.com/2024/06/go-release"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validated, stats := validateURLs([]story.Story{{Headline: "Story", URL: tt.url}}, links, URLValidationDrop)
+			if stats.fixed != 0 || len(validated) != 1 || validated[0].URL != tt.url || !validated[0].URLUnverified {
+				t.Errorf("validateURLs() = %+v, %+v, want the story flagged and unchanged", validated, stats)
+			}
+		})
+	}
+}
+
+func TestValidateURLs_FlagMode(t *testing.T) {
+	links := []email.Link{{URL: "https://example.com/a"}}
+	stories := []story.Story{{Headline: "Invented", URL: "https://made-up.example/story"}}
+
+	validated, stats := validateURLs(stories, links, URLValidationFlag)
+
+	if stats.unmatched != 1 || len(validated) != 1 || !validated[0].URLUnverified {
+		t.Errorf("validateURLs(flag) = %+v, %+v, want one flagged story", validated, stats)
+	}
+}
+
+func TestValidateURLs_ModeIgnoresCase(t *testing.T) {
+	links := []email.Link{{URL: "https://example.com/a"}}
+	stories := []story.Story{{Headline: "Invented", URL: "https://made-up.example/story"}}
+
+	if validated, _ := validateURLs(stories, links, "Flag"); len(validated) != 1 || !validated[0].URLUnverified {
+		t.Errorf("validateURLs(Flag) = %+v, want one flagged story", validated)
+	}
+	if validated, _ := validateURLs(stories, links, " OFF "); len(validated) != 1 || validated[0].URLUnverified {
+		t.Errorf("validateURLs(OFF) = %+v, want the story unchanged", validated)
+	}
+}
+
+func TestValidateURLs_SkipsEmailsWithoutLinks(t *testing.T) {
+	stories := []story.Story{{Headline: "Story", URL: "https://example.com/a"}}
+
+	validated, stats := validateURLs(stories, nil, URLValidationDrop)
+
+	if len(validated) != 1 || stats != (urlStats{}) {
+		t.Errorf("validateURLs() = %+v, %+v, want stories unchanged", validated, stats)
+	}
+}
+
+func TestLinkIndex_AmbiguousFuzzyMatch(t *testing.T) {
+	idx := newLinkIndex([]email.Link{
+		{URL: "https://example.com/story-1a"},
+		{URL: "https://example.com/story-1b"},
+	})
+
+	if _, kind := idx.match("https://example.com/story-1c"); kind != matchSuspect {
+		t.Errorf("match() kind = %v, want matchSuspect for ambiguous candidates", kind)
+	}
+}
+
+func TestBoundedLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"kitten", "sitting", 5, 3},
+		{"same", "same", 2, 0},
+		{"abc", "abcdef", 2, 3},
+		{"abcdefgh", "hgfedcba", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := boundedLevenshtein(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("boundedLevenshtein(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package extractor
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+// SenderUsage sums the LLM usage of the emails of one newsletter sender.
+type SenderUsage struct {
+	Emails int
+	Usage  story.Usage
+	// Cost is in USD
+	Cost float64
+}
+
+// addUsage adds the usage of an email to the result and its sender's total.
+func (r *Result) addUsage(sender string, usage story.Usage, cost float64) {
+	r.Usage = r.Usage.Add(usage)
+	r.Cost += cost
+
+	if r.Senders == nil {
+		r.Senders = make(map[string]SenderUsage)
+	}
+	s := r.Senders[sender]
+	s.Emails++
+	s.Usage = s.Usage.Add(usage)
+	s.Cost += cost
+	r.Senders[sender] = s
+}
+
+// senderOf identifies the newsletter an email comes from by its sender
+// address, or name if there is none.
+func senderOf(e *email.Email) string {
+	switch {
+	case e.FromEmail != "":
+		return strings.ToLower(e.FromEmail)
+	case e.FromName != "":
+		return e.FromName
+	default:
+		return "unknown"
+	}
+}
+
+// cost returns the price of the usage in USD, or 0 for models without a
+// known price.
+func (p *Processor) cost(usage story.Usage) float64 {
+	if !p.priced {
+		return 0
+	}
+	return p.price.Cost(usage.PromptTokens, usage.CompletionTokens)
+}
+
+// estimateSpend returns the tokens and cost an extraction of the email may
+// spend at most, or zero without budget limits or an extractor that can
+// estimate its usage.
+func (p *Processor) estimateSpend(e *email.Email) (int, float64) {
+	estimator, ok := p.extractor.(story.Estimator)
+	if p.budget == nil || !ok {
+		return 0, 0
+	}
+	est := estimator.Estimate(e)
+	usage := story.Usage{PromptTokens: est.PromptTokens, CompletionTokens: est.MaxOutputTokens}
+	return usage.PromptTokens + usage.CompletionTokens, p.cost(usage)
+}
+
+// logSenderUsage logs the usage of each sender, most expensive first, to
+// show which newsletters cost the most to process.
+func (p *Processor) logSenderUsage(result *Result) {
+	senders := make([]string, 0, len(result.Senders))
+	for sender := range result.Senders {
+		senders = append(senders, sender)
+	}
+	slices.SortFunc(senders, func(a, b string) int {
+		ua, ub := result.Senders[a], result.Senders[b]
+		return cmp.Or(
+			cmp.Compare(ub.Cost, ua.Cost),
+			cmp.Compare(ub.Usage.PromptTokens+ub.Usage.CompletionTokens, ua.Usage.PromptTokens+ua.Usage.CompletionTokens),
+			strings.Compare(a, b))
+	})
+
+	for _, sender := range senders {
+		u := result.Senders[sender]
+		p.log.Info("usage by sender",
+			"sender", sender,
+			"emails", u.Emails,
+			"prompt_tokens", u.Usage.PromptTokens,
+			"completion_tokens", u.Usage.CompletionTokens,
+			"reasoning_tokens", u.Usage.ReasoningTokens,
+			"cost_usd", u.Cost)
+	}
+}
@@ -0,0 +1,216 @@
+// Package ledger records which emails the story extractor has processed, and
+// with what outcome, in a JSON Lines file in the storydir.
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Filename is the name of the ledger file within the storydir. The leading
+// dot hides it from ls, but not from filepath.Glob: story globs skip it only
+// because of its .jsonl extension.
+const Filename = ".ledger.jsonl"
+
+// Outcomes of processing an email.
+const (
+	OutcomeStories   = "stories"
+	OutcomeNoStories = "no-stories"
+	OutcomeError     = "error"
+)
+
+// Entry records the processing of a single email.
+type Entry struct {
+	MessageID string `json:"message_id"`
+	Path      string `json:"path"`
+	// ContentHash is the hex SHA-256 of the raw email file
+	ContentHash string `json:"content_hash"`
+	Outcome     string `json:"outcome"`
+	Stories     int    `json:"stories"`
+	// Partial is set when the stories were salvaged from a broken model reply
+	Partial       bool   `json:"partial,omitempty"`
+	Error         string `json:"error,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	// Token counts and cost in USD of the extraction, including retries
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	ReasoningTokens  int       `json:"reasoning_tokens,omitempty"`
+	Cost             float64   `json:"cost_usd,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// Done reports whether the email needs no further processing. Failed
+// emails are retried on the next run.
+func (e Entry) Done() bool {
+	return e.Outcome == OutcomeStories || e.Outcome == OutcomeNoStories
+}
+
+// Succeeded returns a copy of e recording that the given number of stories
+// were extracted.
+func (e Entry) Succeeded(stories int, partial bool) Entry {
+	e.Outcome = OutcomeNoStories
+	if stories > 0 {
+		e.Outcome = OutcomeStories
+	}
+	e.Stories = stories
+	e.Partial = partial
+	return e
+}
+
+// Failed returns a copy of e recording that processing failed with err.
+func (e Entry) Failed(err error) Entry {
+	e.Outcome = OutcomeError
+	e.Error = err.Error()
+	return e
+}
+
+var errReadOnly = errors.New("ledger is read-only")
+
+// Ledger is an append-only log of processed emails. It is safe for
+// concurrent use. A nil Ledger is empty and discards records, for runs
+// without a ledger.
+type Ledger struct {
+	mu     sync.Mutex
+	file   *os.File         // nil if read-only
+	latest map[string]Entry // latest entry per message ID
+}
+
+// Open reads the ledger in dir and opens it for appending, creating it if
+// needed. Malformed lines, such as one cut off by a crash, are ignored.
+func Open(dir string) (*Ledger, error) {
+	return OpenPath(filepath.Join(dir, Filename))
+}
+
+// OpenPath is like Open, for a ledger file at any path.
+func OpenPath(path string) (*Ledger, error) {
+	latest, data, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // G304: Path is the configured ledger file
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// Terminate a line cut off by a crash, so that the next entry starts on its own line
+		if _, err := file.WriteString("\n"); err != nil {
+			_ = file.Close() //nolint:errcheck // Already failing, the write error is more relevant
+			return nil, fmt.Errorf("failed to repair ledger: %w", err)
+		}
+	}
+
+	return &Ledger{file: file, latest: latest}, nil
+}
+
+// Read reads the ledger in dir without opening it for appending, so that
+// Record fails. A missing ledger is empty.
+func Read(dir string) (*Ledger, error) {
+	return ReadPath(filepath.Join(dir, Filename))
+}
+
+// ReadPath is like Read, for a ledger file at any path.
+func ReadPath(path string) (*Ledger, error) {
+	latest, _, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Ledger{latest: latest}, nil
+}
+
+// read returns the latest entry per message ID of the ledger file at path,
+// and the file's content.
+func read(path string) (map[string]Entry, []byte, error) {
+	latest := make(map[string]Entry)
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is the configured ledger file
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.MessageID == "" {
+			continue
+		}
+		latest[e.MessageID] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return latest, data, nil
+}
+
+// Lookup returns the latest entry for the given message ID.
+func (l *Ledger) Lookup(messageID string) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.latest[messageID]
+	return e, ok
+}
+
+// Entries returns the latest entry of every email in the ledger.
+func (l *Ledger) Entries() []Entry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, 0, len(l.latest))
+	for _, e := range l.latest {
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Record appends an entry to the ledger. A zero Timestamp is set to the
+// current time.
+func (l *Ledger) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errReadOnly
+	}
+	// A single write per entry, so that entries never interleave
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	l.latest[e.MessageID] = e
+	return nil
+}
+
+// Close closes the ledger file.
+func (l *Ledger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
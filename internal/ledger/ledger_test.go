@@ -0,0 +1,174 @@
+package ledger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpen_EmptyDir(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	defer l.Close() //nolint:errcheck // Test cleanup
+
+	if _, ok := l.Lookup("<id@example.com>"); ok {
+		t.Error("Lookup() found an entry in a new ledger")
+	}
+}
+
+func TestLedger_RecordAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 5, 14, 7, 30, 0, 0, time.UTC)
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	entries := []Entry{
+		{MessageID: "<a@example.com>", Outcome: OutcomeError, Error: "timeout", Timestamp: ts},
+		{MessageID: "<b@example.com>", Outcome: OutcomeNoStories, Model: "gpt-4o-mini", PromptVersion: "default@1", Timestamp: ts},
+		{MessageID: "<a@example.com>", Outcome: OutcomeStories, Stories: 3, Timestamp: ts},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record() unexpected error: %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	defer reopened.Close() //nolint:errcheck // Test cleanup
+
+	a, ok := reopened.Lookup("<a@example.com>")
+	if !ok || a.Outcome != OutcomeStories || a.Stories != 3 {
+		t.Errorf("Lookup(a) = %+v, want the latest entry with 3 stories", a)
+	}
+	b, ok := reopened.Lookup("<b@example.com>")
+	if !ok || b != entries[1] {
+		t.Errorf("Lookup(b) = %+v, want %+v", b, entries[1])
+	}
+	if n := len(reopened.Entries()); n != 2 {
+		t.Errorf("Entries() returned %d entries, want 2", n)
+	}
+}
+
+func TestOpen_IgnoresTornLines(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"message_id":"<a@example.com>","outcome":"stories","stories":1}` + "\n" + `{"message_id":"<b@exa`
+	if err := os.WriteFile(filepath.Join(dir, Filename), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	if err := l.Record(Entry{MessageID: "<c@example.com>", Outcome: OutcomeNoStories}); err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	defer reopened.Close() //nolint:errcheck // Test cleanup
+
+	if _, ok := reopened.Lookup("<a@example.com>"); !ok {
+		t.Error("Lookup(a) should find the complete entry")
+	}
+	if _, ok := reopened.Lookup("<c@example.com>"); !ok {
+		t.Error("Lookup(c) should find the entry appended after the torn line")
+	}
+}
+
+func TestLedger_ConcurrentRecords(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Go(func() {
+			id := "<" + strings.Repeat("x", i+1) + "@example.com>"
+			if err := l.Record(Entry{MessageID: id, Outcome: OutcomeStories}); err != nil {
+				t.Errorf("Record() unexpected error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	defer reopened.Close() //nolint:errcheck // Test cleanup
+
+	if n := len(reopened.Entries()); n != 50 {
+		t.Errorf("Entries() returned %d entries, want 50", n)
+	}
+}
+
+func TestEntry_Outcomes(t *testing.T) {
+	base := Entry{MessageID: "<a@example.com>"}
+
+	if e := base.Succeeded(0, false); e.Outcome != OutcomeNoStories || !e.Done() {
+		t.Errorf("Succeeded(0) = %+v, want done without stories", e)
+	}
+	if e := base.Succeeded(2, true); e.Outcome != OutcomeStories || e.Stories != 2 || !e.Partial || !e.Done() {
+		t.Errorf("Succeeded(2, partial) = %+v, want done with 2 partial stories", e)
+	}
+	if e := base.Failed(errors.New("boom")); e.Outcome != OutcomeError || e.Error != "boom" || e.Done() {
+		t.Errorf("Failed() = %+v, want not done with error", e)
+	}
+}
+
+func TestRead_IsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read() unexpected error: %v", err)
+	}
+	if err := l.Record(Entry{MessageID: "<a@example.com>", Outcome: OutcomeStories}); err == nil {
+		t.Error("Record() on read-only ledger expected error, got nil")
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, Filename)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read() created the ledger file: %v", err)
+	}
+}
+
+func TestLedger_NilIsEmpty(t *testing.T) {
+	var l *Ledger
+
+	if err := l.Record(Entry{MessageID: "<a@example.com>", Outcome: OutcomeStories}); err != nil {
+		t.Errorf("Record() unexpected error: %v", err)
+	}
+	if _, ok := l.Lookup("<a@example.com>"); ok {
+		t.Error("Lookup() found an entry in a nil ledger")
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close() unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+const (
+	anthropicDefaultBaseURL   = "https://api.anthropic.com"
+	anthropicAPIVersion       = "2023-06-01"
+	anthropicDefaultMaxTokens = 8192
+	// anthropicToolName is the tool the model is forced to call with the stories.
+	anthropicToolName = "record_stories"
+)
+
+// AnthropicExtractor uses the Anthropic Messages API to extract stories from
+// emails. The model is forced to call a tool whose input schema is the
+// stories contract, so replies are always structured.
+type AnthropicExtractor struct {
+	client    *http.Client
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+	opts      extractOptions
+}
+
+// NewAnthropicExtractor creates a new Anthropic-based story extractor
+func NewAnthropicExtractor(cfg *config.LLM) *AnthropicExtractor {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+
+	maxTokens := cfg.MaxOutputTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+
+	return &AnthropicExtractor{
+		client:    &http.Client{},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		maxTokens: maxTokens,
+		opts:      newExtractOptions(cfg),
+	}
+}
+
+// Extract processes an email and extracts stories using the Anthropic API.
+func (e *AnthropicExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	stories, _, err := e.ExtractWithUsage(emailData)
+	return stories, err
+}
+
+// ExtractWithUsage is like Extract, and also returns the tokens used.
+func (e *AnthropicExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	return extract(e, e.opts, emailData)
+}
+
+// PromptVersion returns the version of the prompt used for an email.
+func (e *AnthropicExtractor) PromptVersion(emailData *email.Email) string {
+	return e.opts.prompts.forEmail(emailData).version
+}
+
+// Estimate returns the expected usage of extracting an email's stories.
+func (e *AnthropicExtractor) Estimate(emailData *email.Email) story.Estimate {
+	return estimate(e.opts, e.maxTokens, emailData)
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicRequest struct {
+	Model      string              `json:"model"`
+	MaxTokens  int                 `json:"max_tokens"`
+	Messages   []anthropicMessage  `json:"messages"`
+	Tools      []anthropicTool     `json:"tools"`
+	ToolChoice anthropicToolChoice `json:"tool_choice"`
+}
+
+type anthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicUsage counts tokens; thinking is included in the output tokens.
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (e *AnthropicExtractor) complete(ctx context.Context, messages []message) (completion, error) {
+	reqBody := anthropicRequest{
+		Model:     e.model,
+		MaxTokens: e.maxTokens,
+		Tools: []anthropicTool{{
+			Name:        anthropicToolName,
+			Description: "Record the news stories extracted from the email.",
+			InputSchema: storiesSchema,
+		}},
+		ToolChoice: anthropicToolChoice{Type: "tool", Name: anthropicToolName},
+	}
+	for _, m := range messages {
+		reqBody.Messages = append(reqBody.Messages, anthropicMessage(m))
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to encode Anthropic request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to create Anthropic request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", e.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicAPIVersion)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to call Anthropic API: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close() //nolint:errcheck // Body fully read below, nothing to do on close error
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to read Anthropic response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{
+			Provider:   "Anthropic",
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			RetryAfter: parseRetryAfter(resp.Header),
+		}
+		var errResp anthropicErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+			apiErr.Message = errResp.Error.Type + ": " + errResp.Error.Message
+		}
+		return completion{}, fmt.Errorf("failed to call Anthropic API: %w", apiErr)
+	}
+
+	var msgResp anthropicResponse
+	if err := json.Unmarshal(body, &msgResp); err != nil {
+		return completion{}, fmt.Errorf("failed to decode Anthropic response: %w", err)
+	}
+
+	content, ok := msgResp.content()
+	if !ok {
+		return completion{}, fmt.Errorf("no response from Anthropic API")
+	}
+
+	return completion{
+		Content:   content,
+		Truncated: msgResp.StopReason == "max_tokens",
+		Usage: story.Usage{
+			PromptTokens:     msgResp.Usage.InputTokens,
+			CompletionTokens: msgResp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// content returns the stories JSON from the tool call, falling back to the
+// text blocks if the model answered in plain JSON instead.
+func (r *anthropicResponse) content() (string, bool) {
+	var text strings.Builder
+	for _, block := range r.Content {
+		switch block.Type {
+		case "tool_use":
+			if block.Name == anthropicToolName && len(block.Input) > 0 {
+				return string(block.Input), true
+			}
+		case "text":
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), text.Len() > 0
+}
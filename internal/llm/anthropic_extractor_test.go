@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func newFakeAnthropicServer(t *testing.T, reqCh chan<- *http.Request, bodyCh chan<- map[string]any, status int, response any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if reqCh != nil {
+			reqCh <- r
+		}
+		if bodyCh != nil {
+			bodyCh <- body
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	}))
+}
+
+func toolUseResponse(stopReason string, input any) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{
+			{"type": "tool_use", "id": "toolu_1", "name": anthropicToolName, "input": input},
+		},
+		"stop_reason": stopReason,
+	}
+}
+
+func TestAnthropicExtract_SendsToolUseRequest(t *testing.T) {
+	reqCh := make(chan *http.Request, 1)
+	bodyCh := make(chan map[string]any, 1)
+	server := newFakeAnthropicServer(t, reqCh, bodyCh, http.StatusOK,
+		toolUseResponse("tool_use", map[string]any{"stories": []any{}}))
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{
+		APIKey:  "test-key",
+		BaseURL: server.URL,
+		Model:   "claude-sonnet-4-5",
+	})
+
+	if _, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"}); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	req := <-reqCh
+	if req.URL.Path != "/v1/messages" {
+		t.Errorf("request path = %s, want /v1/messages", req.URL.Path)
+	}
+	if req.Header.Get("X-Api-Key") != "test-key" {
+		t.Errorf("x-api-key header = %q, want test-key", req.Header.Get("X-Api-Key"))
+	}
+	if req.Header.Get("Anthropic-Version") == "" {
+		t.Error("request is missing the anthropic-version header")
+	}
+
+	body := <-bodyCh
+	if body["model"] != "claude-sonnet-4-5" {
+		t.Errorf("model = %v, want claude-sonnet-4-5", body["model"])
+	}
+	if body["max_tokens"] != float64(anthropicDefaultMaxTokens) {
+		t.Errorf("max_tokens = %v, want %d", body["max_tokens"], anthropicDefaultMaxTokens)
+	}
+	toolChoice, ok := body["tool_choice"].(map[string]any)
+	if !ok || toolChoice["name"] != anthropicToolName {
+		t.Errorf("tool_choice = %v, want forced %s tool", body["tool_choice"], anthropicToolName)
+	}
+}
+
+func TestAnthropicExtract_ParsesToolInput(t *testing.T) {
+	server := newFakeAnthropicServer(t, nil, nil, http.StatusOK, toolUseResponse("tool_use", map[string]any{
+		"stories": []map[string]any{
+			{"headline": "Claude ships", "teaser": "News. A release.", "url": "https://example.com/claude"},
+		},
+	}))
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "m"})
+	date := time.Date(2024, 5, 14, 7, 30, 0, 0, time.UTC)
+
+	stories, err := extractor.Extract(&email.Email{
+		Subject:   "Test",
+		Body:      "Body",
+		FromEmail: "news@example.com",
+		FromName:  "News",
+		Date:      date,
+	})
+	if err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	if len(stories) != 1 {
+		t.Fatalf("Extract() returned %d stories, want 1", len(stories))
+	}
+	s := stories[0]
+	if s.Headline != "Claude ships" || s.URL != "https://example.com/claude" {
+		t.Errorf("story = %+v, want parsed tool input", s)
+	}
+	if s.FromEmail != "news@example.com" || s.FromName != "News" || !s.Date.Equal(date) {
+		t.Errorf("story = %+v, want email metadata", s)
+	}
+}
+
+func TestAnthropicExtract_FallsBackToTextContent(t *testing.T) {
+	server := newFakeAnthropicServer(t, nil, nil, http.StatusOK, map[string]any{
+		"content": []map[string]any{
+			{"type": "text", "text": `{"stories":[{"headline":"H","teaser":"T","url":"https://example.com"}]}`},
+		},
+		"stop_reason": "end_turn",
+	})
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "m"})
+
+	stories, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if len(stories) != 1 {
+		t.Errorf("Extract() returned %d stories, want 1", len(stories))
+	}
+}
+
+func TestAnthropicExtract_ReturnsErrorOnTruncatedResponse(t *testing.T) {
+	server := newFakeAnthropicServer(t, nil, nil, http.StatusOK,
+		toolUseResponse("max_tokens", map[string]any{"stories": []any{}}))
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "m"})
+
+	_, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body"})
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Extract() error = %v, want truncation error", err)
+	}
+}
+
+func TestAnthropicExtract_ReturnsAPIError(t *testing.T) {
+	server := newFakeAnthropicServer(t, nil, nil, http.StatusUnauthorized, map[string]any{
+		"type":  "error",
+		"error": map[string]any{"type": "authentication_error", "message": "invalid x-api-key"},
+	})
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{APIKey: "bad", BaseURL: server.URL, Model: "m"})
+
+	_, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Extract() error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || !strings.Contains(apiErr.Message, "invalid x-api-key") {
+		t.Errorf("APIError = %+v, want 401 with message", apiErr)
+	}
+}
+
+func TestAnthropicExtractWithUsage_ReportsTokens(t *testing.T) {
+	response := toolUseResponse("tool_use", map[string]any{"stories": []any{}})
+	response["usage"] = map[string]any{"input_tokens": 900, "output_tokens": 120}
+	server := newFakeAnthropicServer(t, nil, nil, http.StatusOK, response)
+	defer server.Close()
+
+	extractor := NewAnthropicExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "m"})
+
+	_, usage, err := extractor.ExtractWithUsage(&email.Email{Subject: "Test", Body: "Body"})
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() unexpected error: %v", err)
+	}
+	if want := (story.Usage{PromptTokens: 900, CompletionTokens: 120}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/story"
+)
+
+// cacheEvictRatio is the share of the maximum size the cache is shrunk to
+// when it overflows, so that eviction doesn't run on every write.
+const cacheEvictRatio = 0.9
+
+// NewCachingExtractor creates the extractor for the configured provider,
+// and caches the model's raw replies on disk as configured in cfg.Cache, so
+// that re-running unchanged emails with the same provider, model, prompt and
+// chunk size costs nothing. Replies are keyed by provider, model and the
+// whole conversation; since they are parsed again on every run, changes to
+// parsing and validation apply to cached replies, too. Failed calls are not
+// cached. When the cache exceeds its size, the least recently used replies
+// are evicted.
+func NewCachingExtractor(cfg *config.LLM, log *slog.Logger) (story.Extractor, error) {
+	next, withDefaults, opts, err := newProviderCompleter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newCachingExtractor(next, &withDefaults, opts, log)
+}
+
+func newCachingExtractor(next completer, cfg *config.LLM, opts extractOptions, log *slog.Logger) (*storingExtractor, error) {
+	dir := cfg.Cache.Dir
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find cache directory, set llm.cache.dir: %w", err)
+		}
+		dir = filepath.Join(userCache, "story-extractor", "responses")
+	}
+	store, err := newReplyStore(dir, cfg, int64(cfg.Cache.MaxSizeMB)<<20)
+	if err != nil {
+		return nil, err
+	}
+	mode := storeCache
+	if cfg.Cache.Refresh {
+		mode = storeRefresh
+	}
+	return newStoringExtractor(next, store, mode, opts, log), nil
+}
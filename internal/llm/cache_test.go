@@ -0,0 +1,258 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// fixedCompleter answers every conversation with the same reply or error.
+type fixedCompleter struct {
+	reply completion
+	err   error
+	calls int
+}
+
+func (c *fixedCompleter) complete(context.Context, []message) (completion, error) {
+	c.calls++
+	return c.reply, c.err
+}
+
+// offlineCompleter fails every call, as if the LLM were unreachable.
+func offlineCompleter() *fixedCompleter {
+	return &fixedCompleter{err: errors.New("LLM must not be called")}
+}
+
+func storiesReply(headline string) completion {
+	return completion{Content: `{"stories":[{"headline":"` + headline + `","teaser":"Released.","url":"https://go.dev/blog"}]}`}
+}
+
+func newTestCache(t *testing.T, next completer, cfg config.LLM) *storingExtractor {
+	t.Helper()
+	c, err := newCachingExtractor(next, &cfg, newExtractOptions(&cfg), logger.New(false))
+	if err != nil {
+		t.Fatalf("newCachingExtractor() unexpected error: %v", err)
+	}
+	return c
+}
+
+func testCacheConfig(dir string) config.LLM {
+	return config.LLM{
+		Provider: "openai",
+		Model:    "gpt-4o-mini",
+		Cache:    config.Cache{Enabled: true, Dir: dir},
+	}
+}
+
+var cachedEmail = &email.Email{
+	MessageID: "<1@example.com>",
+	FromEmail: "news@example.com",
+	Subject:   "Weekly News",
+	Body:      strings.Repeat("Go 1.25 released: https://go.dev/blog\n\n", 10),
+	Date:      time.Date(2024, 5, 14, 7, 30, 0, 0, time.UTC),
+}
+
+func TestCachingExtractor_ServesCachedRepliesOffline(t *testing.T) {
+	cfg := testCacheConfig(t.TempDir())
+
+	online := &fixedCompleter{reply: storiesReply("Go 1.25")}
+	online.reply.Usage = story.Usage{PromptTokens: 1000, CompletionTokens: 100}
+	stories, usage, err := newTestCache(t, online, cfg).ExtractWithUsage(cachedEmail)
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() unexpected error: %v", err)
+	}
+	if len(stories) != 1 || usage.PromptTokens != 1000 {
+		t.Fatalf("ExtractWithUsage() = %v, %+v, want the extracted story and its usage", stories, usage)
+	}
+
+	// A later run uses the populated cache, without calling the LLM
+	offline := offlineCompleter()
+	stories, usage, err = newTestCache(t, offline, cfg).ExtractWithUsage(cachedEmail)
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() from cache unexpected error: %v", err)
+	}
+	if offline.calls != 0 {
+		t.Errorf("LLM called %d times, want 0", offline.calls)
+	}
+	if len(stories) != 1 || stories[0].Headline != "Go 1.25" || stories[0].URL != "https://go.dev/blog" {
+		t.Errorf("stories from cache = %+v, want the extracted story", stories)
+	}
+	if usage != (story.Usage{}) {
+		t.Errorf("usage from cache = %+v, want zero", usage)
+	}
+}
+
+func TestCachingExtractor_StoresRawReplies(t *testing.T) {
+	dir := t.TempDir()
+	reply := completion{Content: `{"stories":[{"headline":"Go 1.25","teaser":"Podcast. Released.","url":"https://go.dev/blog","tags":["Go"]}]}`}
+	if _, err := newTestCache(t, &fixedCompleter{reply: reply}, testCacheConfig(dir)).Extract(cachedEmail); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	// The reply is stored as sent, so that later changes to parsing and
+	// validation apply to it
+	files := mustCacheFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("cache holds %d replies, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored storedReply
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("cache file is not a stored reply: %v", err)
+	}
+	if stored.Reply != reply.Content {
+		t.Errorf("cached reply = %q, want the raw reply %q", stored.Reply, reply.Content)
+	}
+}
+
+func TestCachingExtractor_KeyedByModelAndConversation(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newTestCache(t, &fixedCompleter{reply: storiesReply("A")}, testCacheConfig(dir)).Extract(cachedEmail); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	otherModel := testCacheConfig(dir)
+	otherModel.Model = "gpt-4o"
+	otherPrompt := testCacheConfig(dir)
+	otherPrompt.Prompt = PromptCompact
+	otherTags := testCacheConfig(dir)
+	otherTags.Tags = config.Tags{Vocabulary: []string{"ai"}, AllowNew: true}
+	otherChunkSize := testCacheConfig(dir)
+	otherChunkSize.ChunkSize = 200
+	changedBody := *cachedEmail
+	changedBody.Body += " (updated)"
+
+	tests := []struct {
+		name  string
+		cfg   config.LLM
+		email *email.Email
+	}{
+		{"model", otherModel, cachedEmail},
+		{"prompt", otherPrompt, cachedEmail},
+		{"tag vocabulary", otherTags, cachedEmail},
+		{"chunk size", otherChunkSize, cachedEmail},
+		{"email body", testCacheConfig(dir), &changedBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &fixedCompleter{reply: storiesReply("B")}
+			if _, err := newTestCache(t, next, tt.cfg).Extract(tt.email); err != nil {
+				t.Fatalf("Extract() unexpected error: %v", err)
+			}
+			if next.calls == 0 {
+				t.Error("LLM not called, want a cache miss")
+			}
+		})
+	}
+}
+
+func TestCachingExtractor_DoesNotCacheFailures(t *testing.T) {
+	cfg := testCacheConfig(t.TempDir())
+	failing := &fixedCompleter{err: errors.New("rate limited")}
+	if _, err := newTestCache(t, failing, cfg).Extract(cachedEmail); err == nil {
+		t.Fatal("Extract() succeeded, want the LLM error")
+	}
+
+	next := &fixedCompleter{reply: storiesReply("Complete")}
+	if _, err := newTestCache(t, next, cfg).Extract(cachedEmail); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if next.calls != 1 {
+		t.Errorf("LLM called %d times after a failed call, want 1", next.calls)
+	}
+}
+
+func TestCachingExtractor_Refresh(t *testing.T) {
+	cfg := testCacheConfig(t.TempDir())
+	if _, err := newTestCache(t, &fixedCompleter{reply: storiesReply("Old")}, cfg).Extract(cachedEmail); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	refresh := cfg
+	refresh.Cache.Refresh = true
+	next := &fixedCompleter{reply: storiesReply("New")}
+	if _, err := newTestCache(t, next, refresh).Extract(cachedEmail); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if next.calls != 1 {
+		t.Errorf("LLM called %d times with refresh, want 1", next.calls)
+	}
+
+	// The refreshed reply replaced the old one
+	stories, err := newTestCache(t, offlineCompleter(), cfg).Extract(cachedEmail)
+	if err != nil {
+		t.Fatalf("Extract() from cache unexpected error: %v", err)
+	}
+	if len(stories) != 1 || stories[0].Headline != "New" {
+		t.Errorf("stories from cache = %+v, want the refreshed story", stories)
+	}
+}
+
+func TestCachingExtractor_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testCacheConfig(dir)
+	next := &fixedCompleter{reply: storiesReply(strings.Repeat("x", 400<<10))}
+	c := newTestCache(t, next, cfg)
+	c.completer.store.maxSize = 1 << 20 // Room for two replies
+
+	emails := make([]*email.Email, 3)
+	for i := range emails {
+		e := *cachedEmail
+		e.Subject = string(rune('A' + i))
+		emails[i] = &e
+	}
+
+	if _, err := c.Extract(emails[0]); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if _, err := c.Extract(emails[1]); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	// Make the first reply older, then use it, so the second is the least recently used
+	old := time.Now().Add(-time.Hour)
+	for _, f := range mustCacheFiles(t, dir) {
+		if err := os.Chtimes(f, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Extract(emails[0]); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if _, err := c.Extract(emails[2]); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	if files := mustCacheFiles(t, dir); len(files) != 2 {
+		t.Errorf("cache holds %d replies, want 2", len(files))
+	}
+
+	offline := newTestCache(t, offlineCompleter(), cfg)
+	for i, e := range emails {
+		_, err := offline.Extract(e)
+		if evicted := err != nil; evicted != (i == 1) {
+			t.Errorf("email %d: evicted = %v, want %v", i, evicted, i == 1)
+		}
+	}
+}
+
+func mustCacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkOverlapDivisor sets the overlap between chunks to a tenth of the chunk
+// size, so that a story cut at a chunk boundary is complete in one of them.
+const chunkOverlapDivisor = 10
+
+// splitBody splits a body into chunks of at most maxChars bytes. Chunks end
+// at paragraph boundaries, preferably before a heading, and each chunk
+// repeats up to overlap bytes of trailing paragraphs of the previous one.
+// Paragraphs longer than a chunk are cut at line breaks, then after links,
+// and only then anywhere. A maxChars of zero or less returns the body as a
+// single chunk.
+func splitBody(body string, maxChars, overlap int) []string {
+	if maxChars <= 0 || len(body) <= maxChars {
+		return []string{body}
+	}
+	overlap = min(max(overlap, 0), maxChars/2)
+
+	var blocks []string
+	for _, paragraph := range strings.SplitAfter(body, "\n\n") {
+		blocks = append(blocks, splitLongBlock(paragraph, maxChars)...)
+	}
+
+	var (
+		chunks  []string
+		current []string // blocks of the chunk being built
+		size    int      // bytes in current
+		fresh   int      // blocks in current that are not repeated from the previous chunk
+	)
+	flush := func() {
+		chunks = append(chunks, strings.Join(current, ""))
+		// Carry over trailing blocks as overlap into the next chunk
+		carried, carriedSize := 0, 0
+		for i := len(current) - 1; i > 0 && carriedSize+len(current[i]) <= overlap; i-- {
+			carried++
+			carriedSize += len(current[i])
+		}
+		current = append([]string(nil), current[len(current)-carried:]...)
+		size, fresh = carriedSize, 0
+	}
+
+	for _, block := range blocks {
+		full := size+len(block) > maxChars
+		// Start new sections in a new chunk, unless that leaves the chunk mostly empty
+		sectionStart := isSectionStart(block) && size >= maxChars/2
+		if fresh > 0 && (full || sectionStart) {
+			flush()
+		}
+		// Drop overlap that doesn't leave room for the block
+		for len(current) > 0 && size+len(block) > maxChars {
+			size -= len(current[0])
+			current = current[1:]
+		}
+		current = append(current, block)
+		size += len(block)
+		fresh++
+	}
+	if fresh > 0 && strings.TrimSpace(strings.Join(current, "")) != "" {
+		chunks = append(chunks, strings.Join(current, ""))
+	}
+
+	return chunks
+}
+
+// linkEnd matches the end of a Markdown-style link, as written by the HTML
+// conversion, or of a bare URL.
+var linkEnd = regexp.MustCompile(`\]\([^)\s]*\)|https?://\S+`)
+
+// splitLongBlock cuts a block longer than maxChars into pieces, at line
+// breaks if possible, otherwise after links, otherwise at rune boundaries.
+func splitLongBlock(block string, maxChars int) []string {
+	if len(block) <= maxChars {
+		return []string{block}
+	}
+
+	var pieces []string
+	for len(block) > maxChars {
+		cut := lastCut(block[:maxChars])
+		if cut <= 0 {
+			cut = runeBoundary(block, maxChars)
+		}
+		pieces = append(pieces, block[:cut])
+		block = block[cut:]
+	}
+	if block != "" {
+		pieces = append(pieces, block)
+	}
+	return pieces
+}
+
+// lastCut returns the position after the last line break in s, or after the
+// last link if there is no line break, or 0.
+func lastCut(s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return i + 1
+	}
+	if m := linkEnd.FindAllStringIndex(s, -1); len(m) > 0 {
+		// The last URL may be cut off by the chunk size, so keep it for the next piece
+		last := m[len(m)-1]
+		if last[1] < len(s) {
+			return last[1]
+		}
+		if len(m) > 1 {
+			return m[len(m)-2][1]
+		}
+		return last[0]
+	}
+	return 0
+}
+
+// isSectionStart reports whether a paragraph looks like a heading or section
+// separator: a Markdown heading, a rule like "-----", or a single short line
+// that doesn't end like a sentence.
+func isSectionStart(block string) bool {
+	s := strings.TrimSpace(block)
+	if s == "" || strings.Contains(s, "\n") {
+		return false
+	}
+	if strings.HasPrefix(s, "#") || strings.Trim(s, "-=*_~ ") == "" {
+		return true
+	}
+	if utf8.RuneCountInString(s) > 80 || strings.Contains(s, "](") || strings.Contains(s, "://") {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(s)
+	return !strings.ContainsRune(".,;:!?)\"'", last)
+}
+
+// runeBoundary returns the largest index <= n that does not split a UTF-8 rune.
+func runeBoundary(s string, n int) int {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
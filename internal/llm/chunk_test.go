@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitBody_DisabledOrShortBodyIsSingleChunk(t *testing.T) {
+	body := "One.\n\nTwo."
+	for _, maxChars := range []int{0, -1, len(body)} {
+		chunks := splitBody(body, maxChars, 0)
+		if len(chunks) != 1 || chunks[0] != body {
+			t.Errorf("splitBody(%d) = %q, want body as single chunk", maxChars, chunks)
+		}
+	}
+}
+
+func TestSplitBody_BreaksAtParagraphs(t *testing.T) {
+	body := "First paragraph.\n\nSecond paragraph.\n\nThird."
+
+	chunks := splitBody(body, 30, 0)
+
+	want := []string{"First paragraph.\n\n", "Second paragraph.\n\nThird."}
+	if len(chunks) != len(want) {
+		t.Fatalf("splitBody() = %q, want %q", chunks, want)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestSplitBody_PrefersHeadingBoundaries(t *testing.T) {
+	body := "Intro text goes here.\n\nMore intro.\n\nTools and Libraries\n\nA new tool.\n\n"
+
+	chunks := splitBody(body, 60, 0)
+
+	if len(chunks) != 2 || !strings.HasPrefix(chunks[1], "Tools and Libraries") {
+		t.Errorf("splitBody() = %q, want second chunk to start at the heading", chunks)
+	}
+}
+
+func TestSplitBody_OverlapsChunks(t *testing.T) {
+	body := "Story one is here.\n\nStory two is here.\n\nStory three here.\n\nStory four is here.\n\n"
+
+	chunks := splitBody(body, 45, 20)
+
+	if len(chunks) < 2 {
+		t.Fatalf("splitBody() = %q, want several chunks", chunks)
+	}
+	for i, c := range chunks {
+		if len(c) > 45 {
+			t.Errorf("chunk %d has %d bytes, want at most 45", i, len(c))
+		}
+	}
+	for i := 1; i < len(chunks); i++ {
+		lastOfPrevious := chunks[i-1][strings.LastIndex(strings.TrimSuffix(chunks[i-1], "\n\n"), "\n\n")+2:]
+		if !strings.HasPrefix(chunks[i], lastOfPrevious) {
+			t.Errorf("chunk %d = %q, want it to repeat %q from the previous chunk", i, chunks[i], lastOfPrevious)
+		}
+	}
+	if !strings.Contains(chunks[len(chunks)-1], "Story four") {
+		t.Errorf("last chunk = %q, want the end of the body", chunks[len(chunks)-1])
+	}
+}
+
+func TestSplitBody_CutsLongParagraphsAfterLinks(t *testing.T) {
+	body := "[First story](https://example.com/1) and [Second story](https://example.com/2) and more"
+
+	chunks := splitBody(body, 50, 0)
+
+	if chunks[0] != "[First story](https://example.com/1)" {
+		t.Errorf("first chunk = %q, want cut after the first link", chunks[0])
+	}
+	if strings.Join(chunks, "") != body {
+		t.Error("chunks should join back to the original body")
+	}
+}
+
+func TestSplitBody_CutsLongParagraphsOnRuneBoundaries(t *testing.T) {
+	body := strings.Repeat("ü", 25)
+
+	chunks := splitBody(body, 9, 0)
+
+	for i, c := range chunks {
+		if len(c) > 9 {
+			t.Errorf("chunk %d has %d bytes, want at most 9", i, len(c))
+		}
+		if !utf8.ValidString(c) {
+			t.Errorf("chunk %d = %q splits a rune", i, c)
+		}
+	}
+	if strings.Join(chunks, "") != body {
+		t.Error("chunks should join back to the original body")
+	}
+}
+
+func TestIsSectionStart(t *testing.T) {
+	tests := []struct {
+		block string
+		want  bool
+	}{
+		{"## Tools\n\n", true},
+		{"Tools and Libraries\n\n", true},
+		{"-----\n\n", true},
+		{"This is a sentence.\n\n", false},
+		{"[Read more](https://example.com)\n\n", false},
+		{"Line one\nLine two\n\n", false},
+		{"\n\n", false},
+	}
+	for _, tt := range tests {
+		if got := isSectionStart(tt.block); got != tt.want {
+			t.Errorf("isSectionStart(%q) = %v, want %v", tt.block, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is returned when a provider's API answers with an error status.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by the API's Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed when retried: on rate
+// limits, timeouts and server errors, but not on authentication failures,
+// unknown models or invalid requests.
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	// Includes Anthropic's 529 "overloaded"
+	return e.StatusCode >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. It returns zero if the header is missing or invalid.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
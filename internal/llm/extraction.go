@@ -0,0 +1,249 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+// requestTimeout bounds a single model call. Reasoning models (gpt-5,
+// o-series, extended thinking) need more time due to their thinking step.
+const requestTimeout = 5 * time.Minute
+
+// Chat roles used in conversations with the model.
+const (
+	roleUser      = "user"
+	roleAssistant = "assistant"
+)
+
+// message is a single turn of a conversation with the model.
+type message struct {
+	Role    string
+	Content string
+}
+
+// completion is the raw reply of a model.
+type completion struct {
+	Content string
+	// Truncated is set when the model stopped because it hit the output token limit.
+	Truncated bool
+	Usage     story.Usage
+}
+
+// completer sends a conversation to a model and returns its raw reply. Each
+// provider implements it; prompting and response parsing are shared.
+type completer interface {
+	complete(ctx context.Context, messages []message) (completion, error)
+}
+
+// errTruncated is returned when the model's reply was cut off at the output token limit.
+var errTruncated = errors.New("LLM response truncated: output exceeded token limit")
+
+// extractOptions are the provider-independent extraction settings.
+type extractOptions struct {
+	// prompts selects the prompt template per email
+	prompts *promptSet
+	// chunkSize is the maximum body length in bytes sent in one request;
+	// longer bodies are split into chunks. Zero disables chunking.
+	chunkSize int
+	// limiter is shared by all requests of the extractor; nil for no limits
+	limiter *rateLimiter
+	// tags are offered to the model, and constrain the tags it assigns
+	tags tagVocabulary
+}
+
+// newExtractOptions returns the options configured in cfg, with the built-in
+// prompt variant; see loadExtractOptions.
+func newExtractOptions(cfg *config.LLM) extractOptions {
+	return extractOptions{
+		prompts:   builtinPromptSet(cfg.Prompt),
+		chunkSize: max(cfg.ChunkSize, 0),
+		limiter:   newRateLimiter(cfg.RateLimit),
+		tags:      newTagVocabulary(cfg.Tags),
+	}
+}
+
+// loadExtractOptions is like newExtractOptions, but also loads the prompt
+// file and overrides configured in cfg.
+func loadExtractOptions(cfg *config.LLM) (extractOptions, error) {
+	opts := newExtractOptions(cfg)
+	prompts, err := loadPromptSet(cfg)
+	if err != nil {
+		return extractOptions{}, err
+	}
+	opts.prompts = prompts
+	return opts, nil
+}
+
+// extract runs the extraction prompt for an email through the given completer
+// and converts the model's reply into stories. Long bodies are extracted
+// chunk by chunk, and the stories merged. If some chunks could only be
+// recovered partially, the stories are returned with a story.ErrIncomplete error.
+// The usage of all requests is returned, also on errors.
+func extract(c completer, opts extractOptions, emailData *email.Email) ([]story.Story, story.Usage, error) {
+	prompt := opts.prompts.forEmail(emailData)
+	var extracted []story.ExtractedStory
+	var usage story.Usage
+	var incomplete error
+	for _, chunk := range splitBody(emailData.Body, opts.chunkSize, opts.chunkSize/chunkOverlapDivisor) {
+		text, err := prompt.render(emailData, chunk, opts.tags)
+		if err != nil {
+			return nil, usage, err
+		}
+		chunkStories, chunkUsage, err := extractChunk(c, opts, text)
+		usage = usage.Add(chunkUsage)
+		switch {
+		case errors.Is(err, story.ErrIncomplete):
+			incomplete = err
+		case err != nil:
+			return nil, usage, err
+		}
+		extracted = append(extracted, chunkStories...)
+	}
+
+	return toStories(emailData, prompt.version, opts.tags, dedupeByURL(extracted)), usage, incomplete
+}
+
+// estimate builds the prompts for an email, as extract does, and estimates
+// their usage without calling the model. Repair turns are not included.
+func estimate(opts extractOptions, maxTokens int, emailData *email.Email) story.Estimate {
+	prompt := opts.prompts.forEmail(emailData)
+	var est story.Estimate
+	for _, chunk := range splitBody(emailData.Body, opts.chunkSize, opts.chunkSize/chunkOverlapDivisor) {
+		text, err := prompt.render(emailData, chunk, opts.tags)
+		if err != nil {
+			// Extracting will fail the same way, but the body is sent either way
+			text = chunk
+		}
+		conversation := []message{{Role: roleUser, Content: text}}
+		est.Requests++
+		est.PromptTokens += estimateTokens(conversation)
+		est.MaxOutputTokens += maxTokens
+	}
+	return est
+}
+
+// extractChunk extracts the stories of a single body chunk, given the prompt
+// built for it. Replies that are truncated or not valid JSON get one
+// follow-up turn in the same conversation, asking the model to continue or
+// fix its reply. If that fails too, the complete stories salvaged from the
+// replies are returned with a story.ErrIncomplete error.
+func extractChunk(c completer, opts extractOptions, prompt string) ([]story.ExtractedStory, story.Usage, error) {
+	conversation := []message{{Role: roleUser, Content: prompt}}
+
+	reply, err := completeWithTimeout(c, opts.limiter, conversation)
+	if err != nil {
+		return nil, story.Usage{}, err
+	}
+	usage := reply.Usage
+
+	stories, replyErr := parseReply(reply)
+	if replyErr == nil {
+		return stories, usage, nil
+	}
+
+	salvaged := salvageStories(reply.Content)
+	conversation = append(conversation,
+		message{Role: roleAssistant, Content: reply.Content},
+		message{Role: roleUser, Content: repairPrompt(replyErr, salvaged)})
+
+	if followUp, err := completeWithTimeout(c, opts.limiter, conversation); err == nil {
+		usage = usage.Add(followUp.Usage)
+		more, err := parseReply(followUp)
+		if err == nil {
+			return append(salvaged, more...), usage, nil
+		}
+		salvaged = append(salvaged, salvageStories(followUp.Content)...)
+	}
+
+	if len(salvaged) == 0 {
+		return nil, usage, replyErr
+	}
+	return salvaged, usage, fmt.Errorf("%w: salvaged %d stories: %w", story.ErrIncomplete, len(salvaged), replyErr)
+}
+
+func completeWithTimeout(c completer, limiter *rateLimiter, conversation []message) (completion, error) {
+	if err := limiter.wait(context.Background(), estimateTokens(conversation)); err != nil {
+		return completion{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return c.complete(ctx, conversation)
+}
+
+func parseReply(reply completion) ([]story.ExtractedStory, error) {
+	if reply.Truncated {
+		return nil, errTruncated
+	}
+	return parseStories(reply.Content)
+}
+
+// repairPrompt asks the model to continue a truncated reply after the last
+// complete story, or to fix a reply that is not valid JSON.
+func repairPrompt(replyErr error, salvaged []story.ExtractedStory) string {
+	if !errors.Is(replyErr, errTruncated) {
+		return fmt.Sprintf("Your reply could not be used: %v. Reply again with only the corrected JSON object "+
+			`of the structure {"stories": [...]}, without any other text.`, replyErr)
+	}
+	if len(salvaged) == 0 {
+		return "Your reply was cut off at the output length limit. Reply again with the complete JSON object, " +
+			"but keep the teasers short so that it fits."
+	}
+	return fmt.Sprintf("Your reply was cut off at the output length limit. Continue with a new JSON object "+
+		`of the structure {"stories": [...]} that contains only the stories after the one with URL %s. `+
+		"Keep the teasers short so that it fits.", salvaged[len(salvaged)-1].URL)
+}
+
+// dedupeByURL keeps the first story for each URL. Chunks may mention the same
+// story, e.g. in a table of contents and in the story section.
+func dedupeByURL(stories []story.ExtractedStory) []story.ExtractedStory {
+	seen := make(map[string]bool, len(stories))
+	var unique []story.ExtractedStory
+	for _, s := range stories {
+		if seen[s.URL] {
+			continue
+		}
+		seen[s.URL] = true
+		unique = append(unique, s)
+	}
+	return unique
+}
+
+// toStories converts extracted stories to full stories with email metadata,
+// the version of the prompt they were extracted with, and their tags
+// constrained to the vocabulary.
+func toStories(emailData *email.Email, promptVersion string, tags tagVocabulary, extracted []story.ExtractedStory) []story.Story {
+	var stories []story.Story
+	for _, e := range extracted {
+		s := story.Story{
+			Headline:    e.Headline,
+			Teaser:      e.Teaser,
+			ContentType: contentType(e),
+			URL:         e.URL,
+			FromEmail:   emailData.FromEmail,
+			FromName:    emailData.FromName,
+			Date:        emailData.Date,
+
+			PromptVersion: promptVersion,
+			Tags:          tags.filter(e.Tags),
+		}
+		stories = append(stories, s)
+	}
+	return stories
+}
+
+// contentType returns the content type the model chose, or else the one of
+// the teaser's label, for models that replied without or with an unknown one.
+func contentType(e story.ExtractedStory) string {
+	if t, ok := story.ParseContentType(e.ContentType); ok {
+		return t
+	}
+	return story.ContentTypeFromTeaser(e.Teaser)
+}
@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestDedupeByURL_KeepsFirstStory(t *testing.T) {
+	stories := []story.ExtractedStory{
+		{Headline: "A", URL: "https://example.com/a"},
+		{Headline: "B", URL: "https://example.com/b"},
+		{Headline: "A again", URL: "https://example.com/a"},
+	}
+
+	unique := dedupeByURL(stories)
+
+	if len(unique) != 2 || unique[0].Headline != "A" || unique[1].Headline != "B" {
+		t.Errorf("dedupeByURL() = %+v, want first A and B", unique)
+	}
+}
+
+// scriptedCompleter replies with the given completions in order and records
+// the conversations it was sent.
+type scriptedCompleter struct {
+	replies       []completion
+	conversations [][]message
+}
+
+func (c *scriptedCompleter) complete(_ context.Context, messages []message) (completion, error) {
+	c.conversations = append(c.conversations, messages)
+	if len(c.replies) == 0 {
+		return completion{}, errors.New("no more replies")
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return reply, nil
+}
+
+func TestExtract_ContinuesTruncatedReply(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `{"stories":[{"headline":"One","teaser":"T","url":"https://example.com/1"},{"headline":"Tw`, Truncated: true},
+		{Content: `{"stories":[{"headline":"Two","teaser":"T","url":"https://example.com/2"}]}`},
+	}}
+
+	stories, _, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+
+	if len(stories) != 2 || stories[0].URL != "https://example.com/1" || stories[1].URL != "https://example.com/2" {
+		t.Errorf("extract() = %+v, want salvaged and continued stories", stories)
+	}
+	if len(c.conversations) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(c.conversations))
+	}
+	followUp := c.conversations[1]
+	if len(followUp) != 3 || followUp[1].Role != roleAssistant || !strings.Contains(followUp[2].Content, "https://example.com/1") {
+		t.Errorf("follow-up conversation = %+v, want continuation after the last complete story", followUp)
+	}
+}
+
+func TestExtract_FixesInvalidJSON(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `Here are the stories: {"stories": [...]}`},
+		{Content: `{"stories":[{"headline":"One","teaser":"T","url":"https://example.com/1"}]}`},
+	}}
+
+	stories, _, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+	if len(stories) != 1 {
+		t.Errorf("extract() returned %d stories, want 1 from the fixed reply", len(stories))
+	}
+	if !strings.Contains(c.conversations[1][2].Content, "corrected JSON") {
+		t.Errorf("follow-up = %q, want request to fix the JSON", c.conversations[1][2].Content)
+	}
+}
+
+func TestExtract_ReturnsSalvagedStoriesWhenRepairFails(t *testing.T) {
+	truncated := completion{
+		Content:   `{"stories":[{"headline":"One","teaser":"T","url":"https://example.com/1"},{"headline":"Tw`,
+		Truncated: true,
+	}
+	c := &scriptedCompleter{replies: []completion{truncated, truncated}}
+
+	stories, _, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+
+	if !errors.Is(err, story.ErrIncomplete) || !errors.Is(err, errTruncated) {
+		t.Fatalf("extract() error = %v, want incomplete truncation error", err)
+	}
+	if len(stories) != 1 || stories[0].URL != "https://example.com/1" {
+		t.Errorf("extract() = %+v, want the salvaged story once", stories)
+	}
+}
+
+func TestExtract_FailsWithoutSalvageableStories(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{{Content: "not json"}, {Content: "still not json"}}}
+
+	stories, _, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+
+	if err == nil || errors.Is(err, story.ErrIncomplete) {
+		t.Errorf("extract() error = %v, want plain parse error", err)
+	}
+	if stories != nil {
+		t.Errorf("extract() = %+v, want no stories", stories)
+	}
+}
+
+func TestSalvageStories(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"truncated in story", `{"stories":[{"headline":"A","teaser":"","url":"https://a.example"},{"headline":"B","te`, 1},
+		{"truncated after story", `{"stories":[{"headline":"A","teaser":"","url":"https://a.example"},`, 1},
+		{"code fence", "```json\n{\"stories\":[{\"headline\":\"A\",\"teaser\":\"\",\"url\":\"https://a.example\"}", 1},
+		{"broken later", `{"stories":[{"headline":"A","teaser":"","url":"https://a.example"} {"headline":"B"}]}`, 1},
+		{"no array", `{"stories":`, 0},
+		{"prose", `I could not find any stories.`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := salvageStories(tt.content); len(got) != tt.want {
+				t.Errorf("salvageStories() = %+v, want %d stories", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimate_CountsChunks(t *testing.T) {
+	body := strings.Repeat("A paragraph of a long digest.\n\n", 100)
+	emailData := &email.Email{Subject: "Digest", Body: body}
+
+	whole := estimate(extractOptions{}, 1000, emailData)
+	if whole.Requests != 1 || whole.MaxOutputTokens != 1000 {
+		t.Errorf("estimate() without chunking = %+v, want 1 request", whole)
+	}
+	if bodyTokens := len(body) / 4; whole.PromptTokens < bodyTokens {
+		t.Errorf("PromptTokens = %d, want at least %d for the body", whole.PromptTokens, bodyTokens)
+	}
+
+	chunked := estimate(extractOptions{chunkSize: 1000}, 1000, emailData)
+	if chunked.Requests < 3 || chunked.MaxOutputTokens != chunked.Requests*1000 {
+		t.Errorf("estimate() with chunking = %+v, want a request per chunk", chunked)
+	}
+}
+
+func TestExtract_SumsUsageOfRepairTurns(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `not json`, Usage: story.Usage{PromptTokens: 100, CompletionTokens: 10}},
+		{Content: `{"stories":[]}`, Usage: story.Usage{PromptTokens: 120, CompletionTokens: 5, ReasoningTokens: 2}},
+	}}
+
+	_, usage, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+	if want := (story.Usage{PromptTokens: 220, CompletionTokens: 15, ReasoningTokens: 2}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
+func TestExtract_ContentType(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `{"stories":[
+			{"headline":"One","teaser":"Article. T","content_type":"Podcast","url":"https://example.com/1"},
+			{"headline":"Two","teaser":"GitHub Repo. T","url":"https://example.com/2"},
+			{"headline":"Three","teaser":"Video. T","content_type":"movie","url":"https://example.com/3"}
+		]}`},
+	}}
+
+	stories, _, err := extract(c, extractOptions{}, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+	want := []string{story.ContentPodcast, story.ContentRepo, story.ContentVideo}
+	if len(stories) != len(want) {
+		t.Fatalf("extract() = %+v, want %d stories", stories, len(want))
+	}
+	for i, s := range stories {
+		if s.ContentType != want[i] {
+			t.Errorf("stories[%d].ContentType = %q, want %q", i, s.ContentType, want[i])
+		}
+	}
+}
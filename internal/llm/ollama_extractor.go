@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+const (
+	ollamaDefaultBaseURL   = "http://localhost:11434"
+	ollamaDefaultMaxTokens = 4096
+	// ollamaDefaultChunkSize keeps prompt and reply within the 8k token
+	// context window common for small local models.
+	ollamaDefaultChunkSize = 12000
+)
+
+// OllamaExtractor uses a local Ollama server's native chat API to extract
+// stories, so newsletter content never leaves the machine. The reply is
+// constrained to the stories JSON schema via Ollama's structured outputs.
+type OllamaExtractor struct {
+	client    *http.Client
+	baseURL   string
+	model     string
+	maxTokens int
+	opts      extractOptions
+}
+
+// NewOllamaExtractor creates a new Ollama-based story extractor
+func NewOllamaExtractor(cfg *config.LLM) *OllamaExtractor {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaDefaultBaseURL
+	}
+
+	maxTokens := cfg.MaxOutputTokens
+	if maxTokens <= 0 {
+		maxTokens = ollamaDefaultMaxTokens
+	}
+
+	return &OllamaExtractor{
+		client:    &http.Client{},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		model:     cfg.Model,
+		maxTokens: maxTokens,
+		opts:      newExtractOptions(cfg),
+	}
+}
+
+// Extract processes an email and extracts stories using the Ollama API.
+func (e *OllamaExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	stories, _, err := e.ExtractWithUsage(emailData)
+	return stories, err
+}
+
+// ExtractWithUsage is like Extract, and also returns the tokens used.
+func (e *OllamaExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	return extract(e, e.opts, emailData)
+}
+
+// PromptVersion returns the version of the prompt used for an email.
+func (e *OllamaExtractor) PromptVersion(emailData *email.Email) string {
+	return e.opts.prompts.forEmail(emailData).version
+}
+
+// Estimate returns the expected usage of extracting an email's stories.
+func (e *OllamaExtractor) Estimate(emailData *email.Email) story.Estimate {
+	return estimate(e.opts, e.maxTokens, emailData)
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int     `json:"num_predict"`
+	Temperature float64 `json:"temperature"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   map[string]any  `json:"format"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaResponse struct {
+	Message    ollamaMessage `json:"message"`
+	DoneReason string        `json:"done_reason"`
+	// PromptEvalCount and EvalCount are the prompt and reply tokens
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+type ollamaErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (e *OllamaExtractor) complete(ctx context.Context, messages []message) (completion, error) {
+	reqBody := ollamaRequest{
+		Model:  e.model,
+		Stream: false,
+		Format: storiesSchema,
+		// Low temperature keeps small models close to the schema and the email's wording
+		Options: ollamaOptions{NumPredict: e.maxTokens, Temperature: 0},
+	}
+	for _, m := range messages {
+		reqBody.Messages = append(reqBody.Messages, ollamaMessage(m))
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to encode Ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to call Ollama API: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close() //nolint:errcheck // Body fully read below, nothing to do on close error
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return completion{}, fmt.Errorf("failed to read Ollama response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{
+			Provider:   "Ollama",
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			RetryAfter: parseRetryAfter(resp.Header),
+		}
+		var errResp ollamaErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			apiErr.Message = errResp.Error
+		}
+		return completion{}, fmt.Errorf("failed to call Ollama API: %w", apiErr)
+	}
+
+	var chatResp ollamaResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return completion{}, fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+
+	if chatResp.Message.Content == "" {
+		return completion{}, fmt.Errorf("no response from Ollama API")
+	}
+
+	return completion{
+		Content:   chatResp.Message.Content,
+		Truncated: chatResp.DoneReason == "length",
+		Usage: story.Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+		},
+	}, nil
+}
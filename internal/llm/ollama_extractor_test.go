@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func newFakeOllamaServer(t *testing.T, bodyCh chan<- map[string]any, status int, response any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if bodyCh != nil {
+			bodyCh <- body
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	}))
+}
+
+func ollamaChatResponse(doneReason, content string) map[string]any {
+	return map[string]any{
+		"model":       "llama3.1:8b",
+		"message":     map[string]any{"role": "assistant", "content": content},
+		"done":        true,
+		"done_reason": doneReason,
+	}
+}
+
+func TestOllamaExtract_SendsStructuredChatRequest(t *testing.T) {
+	bodyCh := make(chan map[string]any, 1)
+	server := newFakeOllamaServer(t, bodyCh, http.StatusOK, ollamaChatResponse("stop", `{"stories":[]}`))
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL + "/", Model: "llama3.1:8b"})
+
+	if _, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"}); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	body := <-bodyCh
+	if body["model"] != "llama3.1:8b" {
+		t.Errorf("model = %v, want llama3.1:8b", body["model"])
+	}
+	if body["stream"] != false {
+		t.Errorf("stream = %v, want false", body["stream"])
+	}
+	if format, ok := body["format"].(map[string]any); !ok || format["type"] != "object" {
+		t.Errorf("format = %v, want stories JSON schema", body["format"])
+	}
+	options, ok := body["options"].(map[string]any)
+	if !ok || options["num_predict"] != float64(ollamaDefaultMaxTokens) {
+		t.Errorf("options = %v, want num_predict %d", body["options"], ollamaDefaultMaxTokens)
+	}
+}
+
+func TestOllamaExtract_ParsesStories(t *testing.T) {
+	server := newFakeOllamaServer(t, nil, http.StatusOK, ollamaChatResponse("stop",
+		`{"stories":[{"headline":"Local LLMs","teaser":"Article. Runs offline.","url":"https://example.com/local"}]}`))
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL, Model: "m"})
+
+	stories, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body", FromEmail: "news@example.com"})
+	if err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if len(stories) != 1 || stories[0].URL != "https://example.com/local" || stories[0].FromEmail != "news@example.com" {
+		t.Errorf("Extract() = %+v, want one story with email metadata", stories)
+	}
+}
+
+func TestOllamaExtract_ReturnsErrorOnTruncatedResponse(t *testing.T) {
+	server := newFakeOllamaServer(t, nil, http.StatusOK, ollamaChatResponse("length", `{"stories":[{"headline":"H`))
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL, Model: "m"})
+
+	_, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body"})
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Extract() error = %v, want truncation error", err)
+	}
+}
+
+func TestOllamaExtract_ReturnsAPIError(t *testing.T) {
+	server := newFakeOllamaServer(t, nil, http.StatusNotFound, map[string]any{
+		"error": `model "llama9" not found, try pulling it first`,
+	})
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL, Model: "llama9"})
+
+	_, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Body"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Extract() error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || !strings.Contains(apiErr.Message, "try pulling it first") {
+		t.Errorf("APIError = %+v, want 404 with message", apiErr)
+	}
+}
+
+func TestOllamaExtract_ChunksLongBodies(t *testing.T) {
+	bodyCh := make(chan map[string]any, 2)
+	server := newFakeOllamaServer(t, bodyCh, http.StatusOK, ollamaChatResponse("stop",
+		`{"stories":[{"headline":"Same","teaser":"Article. Same story.","url":"https://example.com/same"}]}`))
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL, Model: "m", ChunkSize: 20})
+
+	body := "First paragraph.\n\nSecond paragraph."
+	stories, err := extractor.Extract(&email.Email{Subject: "Test", Body: body})
+	if err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	if len(bodyCh) != 2 {
+		t.Errorf("sent %d requests, want one per chunk (2)", len(bodyCh))
+	}
+	if len(stories) != 1 {
+		t.Errorf("Extract() returned %d stories, want duplicates across chunks merged into 1", len(stories))
+	}
+}
+
+func TestOllamaExtractWithUsage_ReportsTokens(t *testing.T) {
+	response := ollamaChatResponse("stop", `{"stories":[]}`)
+	response["prompt_eval_count"] = 700
+	response["eval_count"] = 80
+	server := newFakeOllamaServer(t, nil, http.StatusOK, response)
+	defer server.Close()
+
+	extractor := NewOllamaExtractor(&config.LLM{BaseURL: server.URL, Model: "m"})
+
+	_, usage, err := extractor.ExtractWithUsage(&email.Email{Subject: "Test", Body: "Body"})
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() unexpected error: %v", err)
+	}
+	if want := (story.Usage{PromptTokens: 700, CompletionTokens: 80}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
@@ -2,8 +2,11 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/fxnn/news/internal/config"
@@ -12,10 +15,29 @@ import (
 	openai "github.com/sashabaranov/go-openai"
 )
 
+// openAIDefaultMaxTokens leaves headroom for reasoning models, which spend
+// completion tokens on thinking.
+const openAIDefaultMaxTokens = 16384
+
+// Structured output modes for config.LLM.StructuredOutput.
+const (
+	// StructuredOutputJSONSchema constrains replies to the stories schema
+	// (OpenAI strict structured outputs)
+	StructuredOutputJSONSchema = "json_schema"
+	// StructuredOutputJSONObject only asks for valid JSON, for endpoints
+	// without schema support
+	StructuredOutputJSONObject = "json_object"
+)
+
 // OpenAIExtractor uses OpenAI API to extract stories from emails
 type OpenAIExtractor struct {
-	client *openai.Client
-	model  string
+	client    *openai.Client
+	model     string
+	maxTokens int
+	opts      extractOptions
+	// jsonObjectOnly is set once the endpoint turned out not to support
+	// json_schema, or if json_object mode was configured
+	jsonObjectOnly atomic.Bool
 }
 
 // NewOpenAIExtractor creates a new OpenAI-based story extractor
@@ -24,73 +46,167 @@ func NewOpenAIExtractor(cfg *config.LLM) *OpenAIExtractor {
 	if cfg.BaseURL != "" {
 		clientConfig.BaseURL = cfg.BaseURL
 	}
+	// The client's errors don't carry response headers, so Retry-After is captured by the transport
+	clientConfig.HTTPClient = &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport}}
 
-	return &OpenAIExtractor{
-		client: openai.NewClientWithConfig(clientConfig),
-		model:  cfg.Model,
+	maxTokens := cfg.MaxOutputTokens
+	if maxTokens <= 0 {
+		maxTokens = openAIDefaultMaxTokens
 	}
-}
 
-// response represents the JSON structure returned by the LLM.
-type response struct {
-	Stories []story.ExtractedStory `json:"stories"`
+	e := &OpenAIExtractor{
+		client:    openai.NewClientWithConfig(clientConfig),
+		model:     cfg.Model,
+		maxTokens: maxTokens,
+		opts:      newExtractOptions(cfg),
+	}
+	e.jsonObjectOnly.Store(cfg.StructuredOutput == StructuredOutputJSONObject)
+	return e
 }
 
 // Extract processes an email and extracts stories using the OpenAI API.
 func (e *OpenAIExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
-	prompt := buildPrompt(emailData.Subject, emailData.Body)
-
-	// Reasoning models (gpt-5, o-series) need more time due to their thinking step
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	resp, err := e.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: e.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			ResponseFormat: &openai.ChatCompletionResponseFormat{
-				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-			},
-			MaxCompletionTokens: 16384, // Reasoning models spend tokens on thinking, so allow headroom
-		},
-	)
+	stories, _, err := e.ExtractWithUsage(emailData)
+	return stories, err
+}
+
+// ExtractWithUsage is like Extract, and also returns the tokens used.
+func (e *OpenAIExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	return extract(e, e.opts, emailData)
+}
+
+// PromptVersion returns the version of the prompt used for an email.
+func (e *OpenAIExtractor) PromptVersion(emailData *email.Email) string {
+	return e.opts.prompts.forEmail(emailData).version
+}
+
+// Estimate returns the expected usage of extracting an email's stories.
+func (e *OpenAIExtractor) Estimate(emailData *email.Email) story.Estimate {
+	return estimate(e.opts, e.maxTokens, emailData)
+}
+
+func (e *OpenAIExtractor) complete(ctx context.Context, messages []message) (completion, error) {
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, m := range messages {
+		chatMessages[i] = openai.ChatCompletionMessage{Role: m.Role, Content: m.Content}
+	}
 
+	req := openai.ChatCompletionRequest{
+		Model:               e.model,
+		Messages:            chatMessages,
+		ResponseFormat:      e.responseFormat(),
+		MaxCompletionTokens: e.maxTokens,
+	}
+
+	var retryAfter time.Duration
+	ctx = context.WithValue(ctx, retryAfterKey{}, &retryAfter)
+
+	resp, err := e.client.CreateChatCompletion(ctx, req)
+	if err != nil && req.ResponseFormat.Type == openai.ChatCompletionResponseFormatTypeJSONSchema && isUnsupportedSchemaError(err) {
+		// Fall back to JSON mode for OpenAI-compatible endpoints without schema support
+		e.jsonObjectOnly.Store(true)
+		req.ResponseFormat = e.responseFormat()
+		resp, err = e.client.CreateChatCompletion(ctx, req)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to call OpenAI API: %w", err)
+		return completion{}, fmt.Errorf("failed to call OpenAI API: %w", toAPIError(err, retryAfter))
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI API")
+		return completion{}, fmt.Errorf("no response from OpenAI API")
+	}
+
+	return completion{
+		Content:   resp.Choices[0].Message.Content,
+		Truncated: resp.Choices[0].FinishReason == openai.FinishReasonLength,
+		Usage:     toUsage(resp.Usage),
+	}, nil
+}
+
+func toUsage(u openai.Usage) story.Usage {
+	usage := story.Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
 	}
+	return usage
+}
 
-	if resp.Choices[0].FinishReason == openai.FinishReasonLength {
-		return nil, fmt.Errorf("LLM response truncated: output exceeded token limit")
+// responseFormat returns the strict stories schema, or plain JSON mode if
+// the endpoint does not support schemas.
+func (e *OpenAIExtractor) responseFormat() *openai.ChatCompletionResponseFormat {
+	if e.jsonObjectOnly.Load() {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "stories",
+			Schema: storiesSchemaJSON,
+			Strict: true,
+		},
 	}
+}
 
-	var llmResp response
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &llmResp); err != nil {
-		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+// isUnsupportedSchemaError reports whether the API rejected the request
+// because of its json_schema response format, as older models and many
+// OpenAI-compatible servers do.
+func isUnsupportedSchemaError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.HTTPStatusCode != http.StatusBadRequest && apiErr.HTTPStatusCode != http.StatusUnprocessableEntity {
+			return false
+		}
+		return mentionsResponseFormat(apiErr.Message)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		if reqErr.HTTPStatusCode != http.StatusBadRequest && reqErr.HTTPStatusCode != http.StatusUnprocessableEntity {
+			return false
+		}
+		return mentionsResponseFormat(string(reqErr.Body))
 	}
+	return false
+}
 
-	// Convert extracted stories to full stories with email metadata
-	var stories []story.Story
-	for _, extracted := range llmResp.Stories {
-		s := story.Story{
-			Headline:  extracted.Headline,
-			Teaser:    extracted.Teaser,
-			URL:       extracted.URL,
-			FromEmail: emailData.FromEmail,
-			FromName:  emailData.FromName,
-			Date:      emailData.Date,
+// toAPIError converts the OpenAI client's error types into an *APIError, so
+// that callers can tell rate limits and server errors from permanent errors.
+func toAPIError(err error, retryAfter time.Duration) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return &APIError{Provider: "OpenAI", StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message, RetryAfter: retryAfter}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		message := strings.TrimSpace(string(reqErr.Body))
+		if message == "" && reqErr.Err != nil {
+			message = reqErr.Err.Error()
 		}
-		stories = append(stories, s)
+		return &APIError{Provider: "OpenAI", StatusCode: reqErr.HTTPStatusCode, Message: message, RetryAfter: retryAfter}
+	}
+	return err
+}
+
+// retryAfterKey is the context key under which complete passes a
+// *time.Duration for retryAfterTransport to fill in.
+type retryAfterKey struct{}
+
+// retryAfterTransport records the Retry-After header of error responses.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
 	}
+	if retryAfter, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		*retryAfter = parseRetryAfter(resp.Header)
+	}
+	return resp, nil
+}
 
-	return stories, nil
+func mentionsResponseFormat(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "response_format") || strings.Contains(message, "json_schema")
 }
@@ -2,13 +2,16 @@ package llm
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fxnn/news/internal/config"
 	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
 )
 
 func newFakeOpenAIServer(t *testing.T, bodyCh chan<- map[string]any) *httptest.Server {
@@ -113,3 +116,129 @@ func TestExtract_WorksWithReasoningModels(t *testing.T) {
 		t.Fatalf("Extract() with gpt-5-mini should succeed, got error: %v", err)
 	}
 }
+
+func TestExtract_RequestsStrictJSONSchema(t *testing.T) {
+	bodyCh := make(chan map[string]any, 1)
+	server := newFakeOpenAIServer(t, bodyCh)
+	defer server.Close()
+
+	extractor := NewOpenAIExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "gpt-4o"})
+
+	if _, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"}); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	format, _ := (<-bodyCh)["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("response_format = %v, want json_schema", format)
+	}
+	schema, _ := format["json_schema"].(map[string]any)
+	if schema["strict"] != true || schema["schema"] == nil {
+		t.Errorf("json_schema = %v, want strict stories schema", schema)
+	}
+}
+
+func TestExtract_UsesJSONObjectWhenConfigured(t *testing.T) {
+	bodyCh := make(chan map[string]any, 1)
+	server := newFakeOpenAIServer(t, bodyCh)
+	defer server.Close()
+
+	extractor := NewOpenAIExtractor(&config.LLM{
+		APIKey: "k", BaseURL: server.URL, Model: "m", StructuredOutput: StructuredOutputJSONObject,
+	})
+
+	if _, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"}); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+
+	format, _ := (<-bodyCh)["response_format"].(map[string]any)
+	if format["type"] != "json_object" {
+		t.Errorf("response_format = %v, want json_object", format)
+	}
+}
+
+func TestExtract_FallsBackToJSONObjectWithoutSchemaSupport(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseFormat struct {
+				Type string `json:"type"`
+			} `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		formats = append(formats, body.ResponseFormat.Type)
+
+		w.Header().Set("Content-Type", "application/json")
+		if body.ResponseFormat.Type == "json_schema" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeBody(t, w, `{"error":{"message":"Invalid parameter: 'response_format' of type 'json_schema' is not supported with this model.","type":"invalid_request_error"}}`)
+			return
+		}
+		writeBody(t, w, `{"choices":[{"message":{"content":"{\"stories\":[]}"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	extractor := NewOpenAIExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "gpt-3.5-turbo"})
+
+	for range 2 {
+		if _, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"}); err != nil {
+			t.Fatalf("Extract() unexpected error: %v", err)
+		}
+	}
+
+	want := []string{"json_schema", "json_object", "json_object"}
+	if strings.Join(formats, ",") != strings.Join(want, ",") {
+		t.Errorf("response formats = %v, want %v (fallback remembered)", formats, want)
+	}
+}
+
+func writeBody(t *testing.T, w http.ResponseWriter, body string) {
+	t.Helper()
+	if _, err := w.Write([]byte(body)); err != nil {
+		t.Errorf("failed to write response: %v", err)
+	}
+}
+
+func TestExtract_ReturnsAPIErrorWithRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+		writeBody(t, w, `{"error":{"message":"Rate limit reached","type":"requests"}}`)
+	}))
+	defer server.Close()
+
+	extractor := NewOpenAIExtractor(&config.LLM{APIKey: "k", BaseURL: server.URL, Model: "m"})
+
+	_, err := extractor.Extract(&email.Email{Subject: "Test", Body: "Test body"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Extract() error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 20*time.Second || !apiErr.Temporary() {
+		t.Errorf("APIError = %+v, want temporary 429 with Retry-After 20s", apiErr)
+	}
+}
+
+func TestExtractWithUsage_ReportsTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeBody(t, w, `{"choices":[{"message":{"content":"{\"stories\":[]}"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1200,"completion_tokens":300,"completion_tokens_details":{"reasoning_tokens":256}}}`)
+	}))
+	defer server.Close()
+
+	extractor := NewOpenAIExtractor(&config.LLM{APIKey: "test-key", BaseURL: server.URL, Model: "gpt-5-mini"})
+
+	_, usage, err := extractor.ExtractWithUsage(&email.Email{Subject: "Test", Body: "Test body"})
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() unexpected error: %v", err)
+	}
+	want := story.Usage{PromptTokens: 1200, CompletionTokens: 300, ReasoningTokens: 256}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
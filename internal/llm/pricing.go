@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+// Price is the price of a model in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the price of the given token counts in USD.
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// defaultPrices are the list prices of common hosted models. They change
+// from time to time; [[llm.models]] entries with prices take precedence.
+var defaultPrices = map[string]Price{
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"gpt-5":             {Input: 1.25, Output: 10.00},
+	"gpt-5-mini":        {Input: 0.25, Output: 2.00},
+	"gpt-5-nano":        {Input: 0.05, Output: 0.40},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"claude-opus-4-1":   {Input: 15.00, Output: 75.00},
+	"claude-sonnet-4-5": {Input: 3.00, Output: 15.00},
+	"claude-haiku-4-5":  {Input: 1.00, Output: 5.00},
+}
+
+// Prices returns the price table: the default prices, overridden by the
+// prices of [[llm.models]] entries. Model names are lower case.
+func Prices(cfg *config.LLM) map[string]Price {
+	prices := make(map[string]Price, len(defaultPrices)+len(cfg.Models))
+	for name, p := range defaultPrices {
+		prices[name] = p
+	}
+	for _, m := range cfg.Models {
+		if m.InputPrice != 0 || m.OutputPrice != 0 {
+			prices[strings.ToLower(m.Name)] = Price{Input: m.InputPrice, Output: m.OutputPrice}
+		}
+	}
+	return prices
+}
+
+// PriceOf returns the price of the configured model. Models of local
+// providers are free; hosted models without a known price are not found.
+func PriceOf(cfg *config.LLM) (Price, bool) {
+	if p, ok := providers[strings.ToLower(strings.TrimSpace(cfg.Provider))]; ok && !p.requiresAPIKey {
+		return Price{}, true
+	}
+	p, ok := Prices(cfg)[strings.ToLower(cfg.Model)]
+	return p, ok
+}
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+func TestPrice_Cost(t *testing.T) {
+	p := Price{Input: 0.15, Output: 0.60}
+	if got := p.Cost(2_000_000, 500_000); math.Abs(got-0.60) > 1e-9 {
+		t.Errorf("Cost() = %v, want 0.60", got)
+	}
+}
+
+func TestPriceOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.LLM
+		want   Price
+		wantOK bool
+	}{
+		{"default price", config.LLM{Provider: "openai", Model: "GPT-4o-mini"}, defaultPrices["gpt-4o-mini"], true},
+		{"configured price", config.LLM{Provider: "openai", Model: "gpt-4o-mini", Models: []config.Model{
+			{Name: "gpt-4o-mini", InputPrice: 0.1, OutputPrice: 0.2},
+		}}, Price{Input: 0.1, Output: 0.2}, true},
+		{"model settings without price", config.LLM{Provider: "openai", Model: "gpt-4o", Models: []config.Model{
+			{Name: "gpt-4o", ChunkSize: 1000},
+		}}, defaultPrices["gpt-4o"], true},
+		{"unknown model", config.LLM{Provider: "openai", Model: "my-finetune"}, Price{}, false},
+		{"local model", config.LLM{Provider: "ollama", Model: "llama3.1:8b"}, Price{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PriceOf(&tt.cfg)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("PriceOf() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -1,9 +1,23 @@
 package llm
 
-import "fmt"
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
 
 // extractionPromptTemplate is the prompt sent to the LLM to extract stories
-// from a newsletter email. It contains two format verbs: subject and body.
+// from a newsletter email. It is a text/template executed with PromptData.
 const extractionPromptTemplate = `Your task is to extract news stories from an email. But first, decide whether this email is a CONTENT NEWSLETTER or not.
 
 A content newsletter curates links to external articles, blog posts, podcasts, videos, repos, etc. — it points readers to content hosted elsewhere.
@@ -14,10 +28,10 @@ These are NOT content newsletters — return {"stories": []} for them:
 - Promotional emails with shopping links, discount codes, or product showcases
 - Emails where all links point back to the sender's own website/shop/app
 
-Subject: %s
+Subject: {{.Subject}}
 
 Body:
-%s
+{{.Body}}
 
 Return a JSON object with this exact structure:
 {
@@ -25,7 +39,9 @@ Return a JSON object with this exact structure:
     {
       "headline": "Story headline",
       "teaser": "Article. Short teaser text about the linked content.",
-      "url": "https://example.com/article"
+      "content_type": "article",
+      "url": "https://example.com/article",
+      "tags": ["topic"]
     }
   ]
 }
@@ -41,12 +57,17 @@ FORMATTING RULES:
 - Write the headline and teaser in the same language as the original email
 - Keep headlines SHORT: maximum 5-8 words
 - Always start the teaser with a short content type label (1-2 words) followed by a period, e.g. "Article.", "Blog post.", "Podcast.", "Video.", "LinkedIn Post.", "GitHub Repo.", "Research Paper.", "News.", "Tutorial.", "Talk.", "Tool."
+- Set content_type to the type of the linked content, matching the teaser's label, as one of: {{join .ContentTypes}}
 - If the newsletter already contains a summary paragraph describing the linked content, reuse that summary word-for-word after the content type prefix, regardless of length
 - Otherwise, write teasers of 2-4 sentences. Prefer longer, more informative summaries over short ones.
 - Each story MUST have a unique URL link to the actual article
+- Links in the body are written as [link text](url). Copy story URLs exactly from these links, never invent or shorten them
 - If there is only one URL in the email, create only one story
 - Separate stories should have separate URLs - do not create multiple stories for a single URL
 
+TAGGING RULES:
+{{template "tags" .}}
+
 WHAT TO EXTRACT:
 - Each story should be a MAIN article/post/resource being featured in the newsletter
 - Extract the primary link for each distinct story/article
@@ -64,6 +85,179 @@ EXCLUSION RULES (apply these BEFORE adding any story):
 - If there are no valid stories with URLs, return {"stories": []}
 `
 
-func buildPrompt(subject, body string) string {
-	return fmt.Sprintf(extractionPromptTemplate, subject, body)
+// compactPromptTemplate is a shorter variant of extractionPromptTemplate for
+// small local models, which follow fewer, plainer rules more reliably and
+// have little context to spare.
+const compactPromptTemplate = `Extract the news stories from this newsletter email as JSON.
+
+Subject: {{.Subject}}
+
+Body:
+{{.Body}}
+
+Reply with exactly this JSON structure:
+{"stories": [{"headline": "Short headline", "teaser": "Article. Two sentences about the linked content.", "content_type": "article", "url": "https://example.com/article", "tags": ["topic"]}]}
+
+Rules:
+- One story per linked article, blog post, podcast, video, repo or paper. Each story has its own URL.
+- Links are written as [link text](url). Copy URLs exactly, never invent them.
+- Headline: at most 8 words. Teaser: starts with a content type like "Article.", "Podcast.", "Video.", "GitHub Repo.", then 2-4 sentences.
+- content_type: one of {{join .ContentTypes}}.
+- Write in the language of the email.
+{{template "tags" .}}
+- Skip unsubscribe, privacy, imprint, social media, sponsored, advertising and shopping links.
+- If the email is marketing, transactional or has no stories, reply {"stories": []}.
+`
+
+// tagsPromptTemplate is the tagging rule shared by the built-in prompts,
+// included as {{template "tags" .}}. Prompt files may include it, too.
+const tagsPromptTemplate = `{{define "tags" -}}
+{{if .Tags -}}
+- Tags: 1-3 topics of the story from this list: {{join .Tags}}.
+{{- if .NewTags}} If no topic of the list fits, use a new short topic in lowercase English.
+{{- else}} Use no other topics; if none fits, leave the tags empty.{{end}}
+{{- else if .NewTags -}}
+- Tags: 1-3 short topics of the story in lowercase English, e.g. "ai", "security", "politics".
+{{- else -}}
+- Tags: always leave the tags empty.
+{{- end}}
+{{- end}}`
+
+// promptFuncs are the functions available to prompt templates.
+var promptFuncs = template.FuncMap{
+	// join lists values separated by commas
+	"join": func(values []string) string { return strings.Join(values, ", ") },
+}
+
+// Prompt variants selectable via llm.prompt.
+const (
+	PromptDefault = "default"
+	PromptCompact = "compact"
+)
+
+var promptTemplates = map[string]string{
+	PromptDefault: extractionPromptTemplate,
+	PromptCompact: compactPromptTemplate,
+}
+
+// PromptData are the variables available to prompt templates.
+type PromptData struct {
+	Subject string
+	// Body is the email body, or the part of it extracted by this request
+	Body       string
+	Sender     string
+	SenderName string
+	Date       time.Time
+	// Links are all links of the email
+	Links []email.Link
+	// ListID is the mailing list identifier from the List-Id header
+	ListID string
+	// Language is the configured language of the newsletter, or else the
+	// email's Content-Language; may be empty
+	Language string
+	// Tags is the vocabulary of topic tags to assign to stories; NewTags
+	// allows the model to propose others
+	Tags    []string
+	NewTags bool
+	// ContentTypes are the content types a story can have
+	ContentTypes []string
+}
+
+// promptTemplate is a parsed prompt template.
+type promptTemplate struct {
+	// version identifies the template by its name and a hash of its text,
+	// e.g. "default@1a2b3c4d", so that any change yields a new version
+	version string
+	tmpl    *template.Template
+	// language overrides the email's language, if set
+	language string
+}
+
+// newPromptTemplate parses a prompt template, and checks that it can be
+// executed with PromptData.
+func newPromptTemplate(name, text string) (*promptTemplate, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(promptFuncs).Parse(tagsPromptTemplate)
+	if err == nil {
+		_, err = tmpl.Parse(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
+	}
+	sample := PromptData{
+		Subject: "Subject",
+		Body:    "Body",
+		Date:    time.Now(),
+		Links:   []email.Link{{URL: "https://example.com", Text: "Example"}},
+		Tags:    []string{"example"},
+
+		ContentTypes: story.ContentTypes,
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", name, err)
+	}
+
+	// The shared tagging rule and the content types are part of the
+	// version, as they are of the prompt
+	hash := sha256.Sum256([]byte(tagsPromptTemplate + strings.Join(story.ContentTypes, ",") + text))
+	return &promptTemplate{
+		version: name + "@" + hex.EncodeToString(hash[:4]),
+		tmpl:    tmpl,
+	}, nil
+}
+
+// builtinPrompts are the parsed promptTemplates.
+var builtinPrompts = func() map[string]*promptTemplate {
+	prompts := make(map[string]*promptTemplate, len(promptTemplates))
+	for name, text := range promptTemplates {
+		p, err := newPromptTemplate(name, text)
+		if err != nil {
+			panic(err)
+		}
+		prompts[name] = p
+	}
+	return prompts
+}()
+
+// builtinPrompt returns the named built-in prompt variant, or the default
+// prompt for unknown names.
+func builtinPrompt(variant string) *promptTemplate {
+	if p, ok := builtinPrompts[variant]; ok {
+		return p
+	}
+	return builtinPrompts[PromptDefault]
+}
+
+// loadPromptFile reads a prompt template file. Its version is named after
+// the file, without extension.
+func loadPromptFile(path string) (*promptTemplate, error) {
+	text, err := os.ReadFile(path) //nolint:gosec // G304: Path is the configured prompt file
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return newPromptTemplate(name, string(text))
+}
+
+// render builds the prompt for an email, with body as the part of its body
+// to extract, and the tag vocabulary to assign.
+func (p *promptTemplate) render(emailData *email.Email, body string, tags tagVocabulary) (string, error) {
+	data := PromptData{
+		Subject:    emailData.Subject,
+		Body:       body,
+		Sender:     emailData.FromEmail,
+		SenderName: emailData.FromName,
+		Date:       emailData.Date,
+		Links:      emailData.Links,
+		ListID:     emailData.ListID,
+		Language:   cmp.Or(p.language, emailData.Language),
+		Tags:       tags.tags,
+		NewTags:    tags.allowNew,
+
+		ContentTypes: story.ContentTypes,
+	}
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to build prompt %s: %w", p.version, err)
+	}
+	return b.String(), nil
 }
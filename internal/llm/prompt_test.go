@@ -3,6 +3,11 @@ package llm
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
 )
 
 func TestBuildPrompt_ContainsSubjectAndBody(t *testing.T) {
@@ -85,3 +90,134 @@ func TestBuildPrompt_ReuseSummaryTakesPrecedence(t *testing.T) {
 		t.Error("reuse-summary rule should appear before the fallback length rule")
 	}
 }
+
+func TestBuildPrompt_ExplainsLinkFormat(t *testing.T) {
+	prompt := buildPrompt("subject", "body")
+
+	if !strings.Contains(prompt, "[link text](url)") {
+		t.Error("prompt should explain the Markdown-style link format used in the body")
+	}
+}
+
+func TestBuildPromptVariant_Compact(t *testing.T) {
+	prompt := buildPromptVariant(PromptCompact, "Weekly Digest", "Story body")
+
+	if !strings.Contains(prompt, "Weekly Digest") || !strings.Contains(prompt, "Story body") {
+		t.Error("compact prompt should contain subject and body")
+	}
+	if len(prompt) >= len(buildPrompt("Weekly Digest", "Story body")) {
+		t.Error("compact prompt should be shorter than the default prompt")
+	}
+}
+
+func TestBuildPrompt_ListsContentTypes(t *testing.T) {
+	for _, variant := range []string{PromptDefault, PromptCompact} {
+		prompt := buildPromptVariant(variant, "s", "b")
+		if !strings.Contains(prompt, strings.Join(story.ContentTypes, ", ")) {
+			t.Errorf("%s prompt should list the content types", variant)
+		}
+	}
+}
+
+func TestBuildPromptVariant_UnknownFallsBackToDefault(t *testing.T) {
+	if buildPromptVariant("verbose", "s", "b") != buildPrompt("s", "b") {
+		t.Error("unknown prompt variant should fall back to the default prompt")
+	}
+}
+
+func TestPromptVersion_NamesVariantAndHash(t *testing.T) {
+	compact := builtinPrompt(PromptCompact).version
+	if !strings.HasPrefix(compact, "compact@") || len(compact) != len("compact@")+8 {
+		t.Errorf("compact version = %s, want compact@ followed by 8 hex digits", compact)
+	}
+	if got := builtinPrompt("").version; !strings.HasPrefix(got, "default@") {
+		t.Errorf("version of unknown variant = %s, want the default prompt's", got)
+	}
+
+	changed, err := newPromptTemplate(PromptCompact, compactPromptTemplate+"\nBe brief.")
+	if err != nil {
+		t.Fatalf("newPromptTemplate() unexpected error: %v", err)
+	}
+	if changed.version == compact {
+		t.Error("changing the template should change its version")
+	}
+}
+
+func TestPromptTemplate_Variables(t *testing.T) {
+	p, err := newPromptTemplate("custom", `{{.Subject}} from {{.SenderName}} <{{.Sender}}> on {{.Date.Format "2006-01-02"}} in {{.Language}} via {{.ListID}}:
+{{range .Links}}- {{.Text}}: {{.URL}}
+{{end}}{{.Body}}`)
+	if err != nil {
+		t.Fatalf("newPromptTemplate() unexpected error: %v", err)
+	}
+	p.language = "German"
+
+	prompt, err := p.render(&email.Email{
+		Subject:   "Wochenrückblick",
+		FromEmail: "redaktion@example.de",
+		FromName:  "Redaktion",
+		Date:      time.Date(2024, 5, 18, 8, 0, 0, 0, time.UTC),
+		ListID:    "rueckblick.example.de",
+		Language:  "de",
+		Links:     []email.Link{{URL: "https://example.de/a", Text: "Artikel"}},
+		Body:      "whole body",
+	}, "chunk of the body", tagVocabulary{})
+	if err != nil {
+		t.Fatalf("render() unexpected error: %v", err)
+	}
+
+	want := `Wochenrückblick from Redaktion <redaktion@example.de> on 2024-05-18 in German via rueckblick.example.de:
+- Artikel: https://example.de/a
+chunk of the body`
+	if prompt != want {
+		t.Errorf("render() = %q, want %q", prompt, want)
+	}
+}
+
+func TestRender_TagRules(t *testing.T) {
+	tests := []struct {
+		name string
+		tags config.Tags
+		want string
+	}{
+		{"vocabulary", config.Tags{Vocabulary: []string{"ai", "Go"}}, "from this list: ai, go. Use no other topics"},
+		{"vocabulary with new tags", config.Tags{Vocabulary: []string{"ai"}, AllowNew: true}, "from this list: ai. If no topic of the list fits"},
+		{"only new tags", config.Tags{AllowNew: true}, `1-3 short topics of the story in lowercase English`},
+		{"no tags", config.Tags{}, "always leave the tags empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, variant := range []string{PromptDefault, PromptCompact} {
+				prompt, err := builtinPrompt(variant).render(&email.Email{}, "Body", newTagVocabulary(tt.tags))
+				if err != nil {
+					t.Fatalf("render() unexpected error: %v", err)
+				}
+				if !strings.Contains(prompt, tt.want) {
+					t.Errorf("%s prompt does not contain %q:\n%s", variant, tt.want, prompt)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPromptTemplate_RejectsInvalidTemplates(t *testing.T) {
+	for _, text := range []string{"{{.Subject", "{{.Unknown}}"} {
+		if _, err := newPromptTemplate("broken", text); err == nil {
+			t.Errorf("newPromptTemplate(%q) succeeded, want error", text)
+		}
+	}
+}
+
+// buildPrompt builds the default prompt for an email with subject and body.
+func buildPrompt(subject, body string) string {
+	return buildPromptVariant(PromptDefault, subject, body)
+}
+
+// buildPromptVariant builds the prompt of a built-in variant.
+func buildPromptVariant(variant, subject, body string) string {
+	prompt, err := builtinPrompt(variant).render(&email.Email{Subject: subject}, body, newTagVocabulary(config.Tags{Vocabulary: config.DefaultTagVocabulary}))
+	if err != nil {
+		panic(err)
+	}
+	return prompt
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+)
+
+// promptSet selects the prompt template for an email: the one of the first
+// matching override, or else the configured one.
+type promptSet struct {
+	fallback  *promptTemplate
+	overrides []promptOverride
+}
+
+// promptOverride uses its prompt for emails from matching senders or lists.
+type promptOverride struct {
+	senders []string
+	listIDs []string
+	prompt  *promptTemplate
+}
+
+// builtinPromptSet uses the named built-in prompt variant for all emails.
+func builtinPromptSet(variant string) *promptSet {
+	return &promptSet{fallback: builtinPrompt(variant)}
+}
+
+// loadPromptSet loads the prompt files and overrides configured in cfg.
+func loadPromptSet(cfg *config.LLM) (*promptSet, error) {
+	set := builtinPromptSet(cfg.Prompt)
+	if cfg.PromptFile != "" {
+		p, err := loadPromptFile(cfg.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("llm.prompt_file: %w", err)
+		}
+		set.fallback = p
+	}
+
+	for i, o := range cfg.PromptOverrides {
+		override, err := newPromptOverride(o)
+		if err != nil {
+			return nil, fmt.Errorf("llm.prompt_overrides[%d]: %w", i, err)
+		}
+		set.overrides = append(set.overrides, override)
+	}
+	return set, nil
+}
+
+func newPromptOverride(o config.PromptOverride) (promptOverride, error) {
+	if len(o.Senders) == 0 && len(o.ListIDs) == 0 {
+		return promptOverride{}, fmt.Errorf("senders or list_ids are required")
+	}
+	override := promptOverride{senders: lowerAll(o.Senders), listIDs: lowerAll(o.ListIDs)}
+	for _, pattern := range slices.Concat(override.senders, override.listIDs) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return promptOverride{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch {
+	case o.Prompt != "" && o.PromptFile != "":
+		return promptOverride{}, fmt.Errorf("set either prompt or prompt_file")
+	case o.PromptFile != "":
+		p, err := loadPromptFile(o.PromptFile)
+		if err != nil {
+			return promptOverride{}, err
+		}
+		override.prompt = p
+	case o.Prompt != "":
+		p, ok := builtinPrompts[o.Prompt]
+		if !ok {
+			return promptOverride{}, fmt.Errorf("unknown prompt %q (want %s or %s)", o.Prompt, PromptDefault, PromptCompact)
+		}
+		// Copied, since the language differs per override
+		copied := *p
+		override.prompt = &copied
+	default:
+		return promptOverride{}, fmt.Errorf("prompt or prompt_file is required")
+	}
+	override.prompt.language = o.Language
+	return override, nil
+}
+
+// forEmail returns the prompt template to use for an email. A nil set uses
+// the default prompt.
+func (s *promptSet) forEmail(emailData *email.Email) *promptTemplate {
+	if s == nil {
+		return builtinPrompt(PromptDefault)
+	}
+	for _, o := range s.overrides {
+		if o.matches(emailData) {
+			return o.prompt
+		}
+	}
+	return s.fallback
+}
+
+func (o promptOverride) matches(emailData *email.Email) bool {
+	return matchesAny(o.senders, emailData.FromEmail) || matchesAny(o.listIDs, emailData.ListID)
+}
+
+// matchesAny reports whether s matches any of the lowercase glob patterns,
+// ignoring case.
+func matchesAny(patterns []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	s = strings.ToLower(s)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(ss []string) []string {
+	lower := make([]string, len(ss))
+	for i, s := range ss {
+		lower[i] = strings.ToLower(s)
+	}
+	return lower
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func writePromptFile(t *testing.T, name, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewExtractor_PromptFileAndOverrides(t *testing.T) {
+	general := writePromptFile(t, "general.tmpl", "Extract the stories.\n{{.Subject}}\n{{.Body}}")
+	german := writePromptFile(t, "rueckblick.tmpl", "Extrahiere die Meldungen ({{.Language}}).\n{{.Body}}")
+
+	x, err := NewExtractor(&config.LLM{
+		Provider:   "ollama",
+		Model:      "llama3.1:8b",
+		PromptFile: general,
+		PromptOverrides: []config.PromptOverride{
+			{ListIDs: []string{"*.example.de"}, PromptFile: german, Language: "Deutsch"},
+			{Senders: []string{"*@digest.example.com"}, Prompt: PromptCompact},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewExtractor() unexpected error: %v", err)
+	}
+	versioner := x.(story.PromptVersioner)
+
+	tests := []struct {
+		name  string
+		email *email.Email
+		want  string
+	}{
+		{"no override", &email.Email{FromEmail: "news@example.com"}, "general@"},
+		{"list id", &email.Email{FromEmail: "redaktion@example.de", ListID: "rueckblick.example.de"}, "rueckblick@"},
+		{"sender, ignoring case", &email.Email{FromEmail: "News@Digest.Example.com"}, "compact@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versioner.PromptVersion(tt.email); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("PromptVersion() = %s, want %s...", got, tt.want)
+			}
+		})
+	}
+
+	prompt := x.(*OllamaExtractor).opts.prompts.forEmail(tests[1].email)
+	text, err := prompt.render(tests[1].email, "Body", tagVocabulary{})
+	if err != nil {
+		t.Fatalf("render() unexpected error: %v", err)
+	}
+	if !strings.Contains(text, "(Deutsch)") {
+		t.Errorf("render() = %q, want the override's language", text)
+	}
+}
+
+func TestCheckConfig_RejectsInvalidPrompts(t *testing.T) {
+	valid := writePromptFile(t, "valid.tmpl", "{{.Body}}")
+	broken := writePromptFile(t, "broken.tmpl", "{{.Body")
+
+	tests := []struct {
+		name string
+		cfg  config.LLM
+		want string
+	}{
+		{"prompt and prompt file", config.LLM{Prompt: PromptCompact, PromptFile: valid}, "either llm.prompt or llm.prompt_file"},
+		{"missing prompt file", config.LLM{PromptFile: filepath.Join(t.TempDir(), "missing.tmpl")}, "llm.prompt_file"},
+		{"broken prompt file", config.LLM{PromptFile: broken}, "failed to parse prompt template broken"},
+		{"override without patterns", config.LLM{PromptOverrides: []config.PromptOverride{{Prompt: PromptCompact}}}, "senders or list_ids are required"},
+		{"override without prompt", config.LLM{PromptOverrides: []config.PromptOverride{{Senders: []string{"*@example.com"}}}}, "prompt or prompt_file is required"},
+		{"override with unknown prompt", config.LLM{PromptOverrides: []config.PromptOverride{{Senders: []string{"*@example.com"}, Prompt: "tiny"}}}, "unknown prompt"},
+		{"override with bad pattern", config.LLM{PromptOverrides: []config.PromptOverride{{Senders: []string{"[a-"}, Prompt: PromptCompact}}}, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Provider = "ollama"
+			err := CheckConfig(&tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract_StampsPromptVersion(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `{"stories":[{"headline":"One","teaser":"T","url":"https://example.com/1"}]}`},
+	}}
+	opts := extractOptions{prompts: builtinPromptSet(PromptCompact)}
+
+	stories, _, err := extract(c, opts, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+	if len(stories) != 1 || stories[0].PromptVersion != builtinPrompt(PromptCompact).version {
+		t.Errorf("extract() = %+v, want stories stamped with %s", stories, builtinPrompt(PromptCompact).version)
+	}
+}
@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/story"
+)
+
+// provider describes a supported LLM provider.
+type provider struct {
+	// requiresAPIKey is false for providers running locally
+	requiresAPIKey bool
+	// defaults fills in provider-specific defaults for unset config values
+	defaults func(cfg *config.LLM)
+	// newExtractor creates the extractor with the given options, which
+	// include the configured prompt files
+	newExtractor func(cfg *config.LLM, opts extractOptions) story.Extractor
+}
+
+// providers maps the llm.provider config value to its implementation.
+var providers = map[string]provider{
+	"openai": {
+		requiresAPIKey: true,
+		defaults:       hostedDefaults,
+		newExtractor: func(cfg *config.LLM, opts extractOptions) story.Extractor {
+			e := NewOpenAIExtractor(cfg)
+			e.opts = opts
+			return e
+		},
+	},
+	"anthropic": {
+		requiresAPIKey: true,
+		defaults:       hostedDefaults,
+		newExtractor: func(cfg *config.LLM, opts extractOptions) story.Extractor {
+			e := NewAnthropicExtractor(cfg)
+			e.opts = opts
+			return e
+		},
+	},
+	"ollama": {
+		defaults: localDefaults(ollamaDefaultChunkSize),
+		newExtractor: func(cfg *config.LLM, opts extractOptions) story.Extractor {
+			e := NewOllamaExtractor(cfg)
+			e.opts = opts
+			return e
+		},
+	},
+	// llama.cpp's server speaks the OpenAI API, but runs locally with small models
+	"llamacpp": {
+		defaults: func(cfg *config.LLM) {
+			if cfg.BaseURL == "" {
+				cfg.BaseURL = llamaCppDefaultBaseURL
+			}
+			localDefaults(ollamaDefaultChunkSize)(cfg)
+		},
+		newExtractor: func(cfg *config.LLM, opts extractOptions) story.Extractor {
+			e := NewOpenAIExtractor(cfg)
+			e.opts = opts
+			return e
+		},
+	},
+}
+
+const llamaCppDefaultBaseURL = "http://localhost:8080/v1"
+
+// hostedDefaultChunkSize splits only long digests, whose stories would
+// otherwise overflow the output token limit in a single reply.
+const hostedDefaultChunkSize = 60000
+
+func hostedDefaults(cfg *config.LLM) {
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = hostedDefaultChunkSize
+	}
+}
+
+// localDefaults uses the compact prompt and chunks long bodies, since local
+// models are small and have short context windows.
+func localDefaults(chunkSize int) func(cfg *config.LLM) {
+	return func(cfg *config.LLM) {
+		if cfg.Prompt == "" && cfg.PromptFile == "" {
+			cfg.Prompt = PromptCompact
+		}
+		if cfg.ChunkSize == 0 {
+			cfg.ChunkSize = chunkSize
+		}
+	}
+}
+
+// Providers returns the names of all supported providers, sorted.
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckConfig validates the LLM configuration: the provider and prompt
+// variant must be known, prompt files must parse, and hosted providers need
+// an API key.
+func CheckConfig(cfg *config.LLM) error {
+	_, err := configuredProvider(cfg)
+	return err
+}
+
+// NewExtractor creates the story extractor for the configured provider.
+func NewExtractor(cfg *config.LLM) (story.Extractor, error) {
+	p, err := configuredProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	withDefaults := p.withDefaults(cfg)
+	opts, err := loadExtractOptions(&withDefaults)
+	if err != nil {
+		return nil, err
+	}
+	return p.newExtractor(&withDefaults, opts), nil
+}
+
+// withDefaults returns a copy of cfg with the settings of the configured
+// model and the provider defaults applied.
+func (p provider) withDefaults(cfg *config.LLM) config.LLM {
+	withDefaults := *cfg
+	applyModelSettings(&withDefaults)
+	if p.defaults != nil {
+		p.defaults(&withDefaults)
+	}
+	return withDefaults
+}
+
+func configuredProvider(cfg *config.LLM) (provider, error) {
+	p, err := knownProvider(cfg)
+	if err != nil {
+		return provider{}, err
+	}
+	if p.requiresAPIKey && cfg.APIKey == "" {
+		return provider{}, fmt.Errorf("llm.api_key is required (via config or STORY_EXTRACTOR_LLM_API_KEY env var)")
+	}
+	return p, nil
+}
+
+// knownProvider validates the configuration like configuredProvider, except
+// for the API key, which replaying recorded replies doesn't need.
+func knownProvider(cfg *config.LLM) (provider, error) {
+	p, ok := providers[strings.ToLower(strings.TrimSpace(cfg.Provider))]
+	if !ok {
+		return provider{}, fmt.Errorf("unknown llm.provider %q (supported: %s)",
+			cfg.Provider, strings.Join(Providers(), ", "))
+	}
+	if _, ok := promptTemplates[cfg.Prompt]; cfg.Prompt != "" && !ok {
+		return provider{}, fmt.Errorf("unknown llm.prompt %q (want %s or %s)", cfg.Prompt, PromptDefault, PromptCompact)
+	}
+	if cfg.Prompt != "" && cfg.PromptFile != "" {
+		return provider{}, fmt.Errorf("set either llm.prompt or llm.prompt_file")
+	}
+	if _, err := loadPromptSet(cfg); err != nil {
+		return provider{}, err
+	}
+	switch cfg.StructuredOutput {
+	case "", StructuredOutputJSONSchema, StructuredOutputJSONObject:
+	default:
+		return provider{}, fmt.Errorf("unknown llm.structured_output %q (want %s or %s)",
+			cfg.StructuredOutput, StructuredOutputJSONSchema, StructuredOutputJSONObject)
+	}
+	for i, m := range cfg.Models {
+		if m.Name == "" {
+			return provider{}, fmt.Errorf("llm.models[%d]: name is required", i)
+		}
+	}
+	return p, nil
+}
+
+// applyModelSettings applies the [[llm.models]] entry matching the configured model.
+func applyModelSettings(cfg *config.LLM) {
+	for _, m := range cfg.Models {
+		if !strings.EqualFold(m.Name, cfg.Model) {
+			continue
+		}
+		if m.ChunkSize != 0 {
+			cfg.ChunkSize = m.ChunkSize
+		}
+		return
+	}
+}
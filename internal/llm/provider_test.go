@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+func TestNewExtractor_SelectsProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		check    func(any) bool
+	}{
+		{"openai", func(e any) bool { _, ok := e.(*OpenAIExtractor); return ok }},
+		{"Anthropic", func(e any) bool { _, ok := e.(*AnthropicExtractor); return ok }},
+	}
+	for _, tt := range tests {
+		extractor, err := NewExtractor(&config.LLM{Provider: tt.provider, APIKey: "k", Model: "m"})
+		if err != nil {
+			t.Errorf("NewExtractor(%s) unexpected error: %v", tt.provider, err)
+			continue
+		}
+		if !tt.check(extractor) {
+			t.Errorf("NewExtractor(%s) = %T, want matching implementation", tt.provider, extractor)
+		}
+	}
+}
+
+func TestNewExtractor_RejectsUnknownProvider(t *testing.T) {
+	_, err := NewExtractor(&config.LLM{Provider: "gemini", APIKey: "k"})
+	if err == nil {
+		t.Fatal("NewExtractor(gemini) should return an error")
+	}
+	if !strings.Contains(err.Error(), "unknown llm.provider") || !strings.Contains(err.Error(), "anthropic") {
+		t.Errorf("error = %v, want unknown provider listing supported ones", err)
+	}
+}
+
+func TestCheckConfig_RequiresAPIKey(t *testing.T) {
+	err := CheckConfig(&config.LLM{Provider: "anthropic"})
+	if err == nil || !strings.Contains(err.Error(), "api_key is required") {
+		t.Errorf("CheckConfig() error = %v, want missing api_key error", err)
+	}
+}
+
+func TestNewExtractor_LocalProvidersNeedNoAPIKey(t *testing.T) {
+	for _, name := range []string{"ollama", "llamacpp"} {
+		if err := CheckConfig(&config.LLM{Provider: name, Model: "m"}); err != nil {
+			t.Errorf("CheckConfig(%s) unexpected error: %v", name, err)
+		}
+	}
+
+	extractor, err := NewExtractor(&config.LLM{Provider: "ollama", Model: "m"})
+	if err != nil {
+		t.Fatalf("NewExtractor(ollama) unexpected error: %v", err)
+	}
+	ollama, ok := extractor.(*OllamaExtractor)
+	if !ok {
+		t.Fatalf("NewExtractor(ollama) = %T, want *OllamaExtractor", extractor)
+	}
+	if ollama.baseURL != ollamaDefaultBaseURL {
+		t.Errorf("baseURL = %s, want %s", ollama.baseURL, ollamaDefaultBaseURL)
+	}
+	if ollama.opts.prompts.fallback != builtinPrompt(PromptCompact) || ollama.opts.chunkSize != ollamaDefaultChunkSize {
+		t.Errorf("opts = %+v, want compact prompt and default chunk size", ollama.opts)
+	}
+}
+
+func TestNewExtractor_LlamaCppUsesOpenAICompatibleAPI(t *testing.T) {
+	cfg := &config.LLM{Provider: "llamacpp", Model: "m", Prompt: PromptDefault}
+
+	extractor, err := NewExtractor(cfg)
+	if err != nil {
+		t.Fatalf("NewExtractor(llamacpp) unexpected error: %v", err)
+	}
+	openai, ok := extractor.(*OpenAIExtractor)
+	if !ok {
+		t.Fatalf("NewExtractor(llamacpp) = %T, want *OpenAIExtractor", extractor)
+	}
+	if got := openai.opts.prompts.fallback.version; got != builtinPrompt(PromptDefault).version {
+		t.Errorf("prompt = %s, want explicitly configured %s", got, PromptDefault)
+	}
+	if cfg.BaseURL != "" {
+		t.Error("NewExtractor() should not modify the passed config")
+	}
+}
+
+func TestCheckConfig_RejectsUnknownPrompt(t *testing.T) {
+	err := CheckConfig(&config.LLM{Provider: "ollama", Prompt: "tiny"})
+	if err == nil || !strings.Contains(err.Error(), "unknown llm.prompt") {
+		t.Errorf("CheckConfig() error = %v, want unknown prompt error", err)
+	}
+}
+
+func TestCheckConfig_RejectsUnknownStructuredOutput(t *testing.T) {
+	err := CheckConfig(&config.LLM{Provider: "openai", APIKey: "k", StructuredOutput: "xml"})
+	if err == nil || !strings.Contains(err.Error(), "unknown llm.structured_output") {
+		t.Errorf("CheckConfig() error = %v, want unknown structured output error", err)
+	}
+}
+
+func TestNewExtractor_AppliesModelSettings(t *testing.T) {
+	cfg := &config.LLM{
+		Provider:  "openai",
+		APIKey:    "k",
+		Model:     "GPT-4.1-mini",
+		ChunkSize: 30000,
+		Models: []config.Model{
+			{Name: "gpt-4o", ChunkSize: 10000},
+			{Name: "gpt-4.1-mini", ChunkSize: 80000},
+		},
+	}
+
+	extractor, err := NewExtractor(cfg)
+	if err != nil {
+		t.Fatalf("NewExtractor() unexpected error: %v", err)
+	}
+	if got := extractor.(*OpenAIExtractor).opts.chunkSize; got != 80000 {
+		t.Errorf("chunkSize = %d, want 80000 from the model settings", got)
+	}
+}
+
+func TestNewExtractor_ChunkSizeDefaults(t *testing.T) {
+	tests := []struct {
+		chunkSize int
+		want      int
+	}{
+		{0, hostedDefaultChunkSize},
+		{20000, 20000},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		extractor, err := NewExtractor(&config.LLM{Provider: "anthropic", APIKey: "k", ChunkSize: tt.chunkSize})
+		if err != nil {
+			t.Fatalf("NewExtractor() unexpected error: %v", err)
+		}
+		if got := extractor.(*AnthropicExtractor).opts.chunkSize; got != tt.want {
+			t.Errorf("chunk_size %d: chunkSize = %d, want %d", tt.chunkSize, got, tt.want)
+		}
+	}
+}
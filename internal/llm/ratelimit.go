@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+// rateLimiter keeps requests and estimated tokens within per-minute limits
+// shared by all goroutines using an extractor. Each limit is a token bucket
+// holding up to one minute's worth, refilled continuously.
+type rateLimiter struct {
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+	// now and sleep are replaced in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// newRateLimiter returns a limiter for the configured limits, or nil if
+// there are none.
+func newRateLimiter(cfg config.RateLimit) *rateLimiter {
+	if cfg.RequestsPerMinute <= 0 && cfg.TokensPerMinute <= 0 {
+		return nil
+	}
+
+	l := &rateLimiter{now: time.Now, sleep: sleepContext}
+	start := l.now()
+	l.requests = newBucket(cfg.RequestsPerMinute, start)
+	l.tokens = newBucket(cfg.TokensPerMinute, start)
+	return l
+}
+
+// wait blocks until a request of the given estimated token count fits into
+// the limits, or ctx is done. A nil limiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	// Reserve right away, so that concurrent callers queue up behind each other
+	delay := max(l.requests.reserve(1, now), l.tokens.reserve(float64(tokens), now))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+// bucket is a token bucket. Reservations may drive it negative, which
+// delays later reservations until the debt is refilled.
+type bucket struct {
+	capacity  float64
+	perSecond float64
+	available float64
+	last      time.Time
+}
+
+// newBucket returns a full bucket for the given per-minute limit, or nil
+// for no limit.
+func newBucket(perMinute int, now time.Time) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{
+		capacity:  float64(perMinute),
+		perSecond: float64(perMinute) / 60,
+		available: float64(perMinute),
+		last:      now,
+	}
+}
+
+// reserve takes n from the bucket and returns how long to wait until they
+// are actually available. Requests larger than the bucket are capped to its
+// capacity, or they could never proceed.
+func (b *bucket) reserve(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.available = min(b.capacity, b.available+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+
+	b.available -= min(n, b.capacity)
+	if b.available >= 0 {
+		return 0
+	}
+	return time.Duration(-b.available / b.perSecond * float64(time.Second))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// estimateTokens roughly estimates the prompt tokens of a conversation, at
+// four bytes per token.
+func estimateTokens(messages []message) int {
+	n := 0
+	for _, m := range messages {
+		n += len(m.Content)
+	}
+	return n / 4
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+func newTestRateLimiter(cfg config.RateLimit) (*rateLimiter, *[]time.Duration) {
+	var mu sync.Mutex
+	var waits []time.Duration
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := newRateLimiter(cfg)
+	l.now = func() time.Time { return now }
+	l.requests = newBucket(cfg.RequestsPerMinute, now)
+	l.tokens = newBucket(cfg.TokensPerMinute, now)
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		return nil
+	}
+	return l, &waits
+}
+
+func TestNewRateLimiter_NilWithoutLimits(t *testing.T) {
+	l := newRateLimiter(config.RateLimit{})
+	if l != nil {
+		t.Fatal("newRateLimiter() should return nil without limits")
+	}
+	if err := l.wait(context.Background(), 1000); err != nil {
+		t.Errorf("nil limiter wait() = %v, want nil", err)
+	}
+}
+
+func TestRateLimiter_RequestsPerMinute(t *testing.T) {
+	l, waits := newTestRateLimiter(config.RateLimit{RequestsPerMinute: 2})
+
+	for range 4 {
+		if err := l.wait(context.Background(), 0); err != nil {
+			t.Fatalf("wait() unexpected error: %v", err)
+		}
+	}
+
+	// Two requests fit into the burst, then one every 30s, queued behind each other
+	want := []time.Duration{30 * time.Second, 60 * time.Second}
+	if len(*waits) != len(want) {
+		t.Fatalf("waits = %v, want %v", *waits, want)
+	}
+	for i := range want {
+		if (*waits)[i] != want[i] {
+			t.Errorf("wait %d = %v, want %v", i, (*waits)[i], want[i])
+		}
+	}
+}
+
+func TestRateLimiter_TokensPerMinute(t *testing.T) {
+	l, waits := newTestRateLimiter(config.RateLimit{TokensPerMinute: 6000})
+
+	mustWait(t, l, 4000)
+	mustWait(t, l, 4000)
+	// Larger than the whole bucket, capped so that it can proceed eventually
+	mustWait(t, l, 100000)
+
+	want := []time.Duration{20 * time.Second, 80 * time.Second}
+	if len(*waits) != len(want) || (*waits)[0] != want[0] || (*waits)[1] != want[1] {
+		t.Errorf("waits = %v, want %v", *waits, want)
+	}
+}
+
+func mustWait(t *testing.T, l *rateLimiter, tokens int) {
+	t.Helper()
+	if err := l.wait(context.Background(), tokens); err != nil {
+		t.Fatalf("wait() unexpected error: %v", err)
+	}
+}
+
+func TestSleepContext_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := sleepContext(ctx, time.Hour); err == nil {
+		t.Error("sleepContext() should return the context error when cancelled")
+	}
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/story"
+)
+
+// ErrNotRecorded is returned when replaying a conversation that has no
+// recorded reply, typically because the prompt changed since recording.
+var ErrNotRecorded = errors.New("no recorded reply")
+
+// NewRecordingExtractor creates the extractor for the configured provider,
+// and stores each raw model reply in dir for later replay.
+func NewRecordingExtractor(cfg *config.LLM, dir string) (story.Extractor, error) {
+	next, withDefaults, opts, err := newProviderCompleter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordingExtractor(next, dir, &withDefaults, opts)
+}
+
+// NewReplayExtractor creates an extractor that answers with the replies
+// recorded in dir, without calling the model. The configuration must match
+// the recording's, since it determines the prompts; no API key is needed.
+// Conversations without a recorded reply fail with ErrNotRecorded.
+func NewReplayExtractor(cfg *config.LLM, dir string) (story.Extractor, error) {
+	p, err := knownProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	withDefaults := p.withDefaults(cfg)
+	opts, err := loadExtractOptions(&withDefaults)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordingExtractor(nil, dir, &withDefaults, opts)
+}
+
+// newRecordingExtractor records the replies of next in dir, or replays them
+// if next is nil.
+func newRecordingExtractor(next completer, dir string, cfg *config.LLM, opts extractOptions) (*storingExtractor, error) {
+	store, err := newReplyStore(dir, cfg, 0)
+	if err != nil {
+		return nil, err
+	}
+	mode := storeRecord
+	if next == nil {
+		mode = storeReplay
+	}
+	// Recording and replaying never log, but fail instead
+	return newStoringExtractor(next, store, mode, opts, slog.New(slog.DiscardHandler)), nil
+}
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestRecordingExtractor_ReplaysRecordedReplies(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.LLM{Provider: "openai", Model: "gpt-4o-mini"}
+	emailData := &email.Email{Subject: "Weekly", Body: "[Go 1.25](https://go.dev/blog/go1.25)"}
+
+	// The truncated first reply needs a follow-up turn, which is recorded, too
+	model := &scriptedCompleter{replies: []completion{
+		{Content: `{"stories":[{"headline":"Go 1.25","teaser":"T","url":"https://go.dev/blog/go1.25"},{"head`, Truncated: true},
+		{Content: `{"stories":[]}`, Usage: story.Usage{PromptTokens: 100, CompletionTokens: 10}},
+	}}
+	recorder, err := newRecordingExtractor(model, dir, cfg, newExtractOptions(cfg))
+	if err != nil {
+		t.Fatalf("newRecordingExtractor() unexpected error: %v", err)
+	}
+	recorded, err := recorder.Extract(emailData)
+	if err != nil {
+		t.Fatalf("Extract() while recording unexpected error: %v", err)
+	}
+
+	replay, err := NewReplayExtractor(cfg, dir)
+	if err != nil {
+		t.Fatalf("NewReplayExtractor() unexpected error: %v", err)
+	}
+	replayed, usage, err := story.ExtractWithUsage(replay, emailData)
+	if err != nil {
+		t.Fatalf("Extract() while replaying unexpected error: %v", err)
+	}
+
+	if len(replayed) != 1 || len(recorded) != 1 || replayed[0].URL != recorded[0].URL {
+		t.Errorf("replayed %+v, want recorded %+v", replayed, recorded)
+	}
+	if usage.PromptTokens != 100 || usage.CompletionTokens != 10 {
+		t.Errorf("replayed usage = %+v, want the recorded usage", usage)
+	}
+}
+
+func TestRecordingExtractor_ReplayFailsForChangedPrompt(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.LLM{Provider: "openai", Model: "gpt-4o-mini"}
+	model := &scriptedCompleter{replies: []completion{{Content: `{"stories":[]}`}}}
+	recorder, err := newRecordingExtractor(model, dir, cfg, newExtractOptions(cfg))
+	if err != nil {
+		t.Fatalf("newRecordingExtractor() unexpected error: %v", err)
+	}
+	if _, err := recorder.Extract(&email.Email{Subject: "S", Body: "B"}); err != nil {
+		t.Fatalf("Extract() while recording unexpected error: %v", err)
+	}
+
+	compact := *cfg
+	compact.Prompt = PromptCompact
+	replay, err := NewReplayExtractor(&compact, dir)
+	if err != nil {
+		t.Fatalf("NewReplayExtractor() unexpected error: %v", err)
+	}
+	if _, err := replay.Extract(&email.Email{Subject: "S", Body: "B"}); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Extract() error = %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestNewReplayExtractor_NeedsNoAPIKey(t *testing.T) {
+	if _, err := NewReplayExtractor(&config.LLM{Provider: "anthropic"}, t.TempDir()); err != nil {
+		t.Errorf("NewReplayExtractor() unexpected error: %v", err)
+	}
+	if _, err := NewRecordingExtractor(&config.LLM{Provider: "anthropic"}, t.TempDir()); err == nil {
+		t.Error("NewRecordingExtractor() without API key succeeded, want error")
+	}
+}
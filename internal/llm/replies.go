@@ -0,0 +1,347 @@
+package llm
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+// storedReply is a raw model reply, stored as <dir>/<conversation key>.json.
+type storedReply struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	Reply     string `json:"reply"`
+	Truncated bool   `json:"truncated,omitempty"`
+
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	ReasoningTokens  int `json:"reasoning_tokens,omitempty"`
+}
+
+// replyStore keeps raw model replies on disk, keyed by provider, model and
+// the whole conversation. Since the conversation contains the rendered
+// prompt and body chunk, any change to the prompt, tags or chunking misses
+// the stored replies, while changes to how replies are parsed apply to
+// stored replies, too. With a maximum size, the least recently used replies
+// are evicted.
+type replyStore struct {
+	dir      string
+	provider string
+	model    string
+	maxSize  int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+// newReplyStore creates dir if needed and opens the replies stored in it for
+// the configured provider and model. maxSize is in bytes; 0 means no limit.
+func newReplyStore(dir string, cfg *config.LLM, maxSize int64) (*replyStore, error) {
+	// Replies quote the newsletters, so keep them private like story files
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create reply directory: %w", err)
+	}
+	s := &replyStore{
+		dir:      dir,
+		provider: strings.ToLower(cfg.Provider),
+		model:    cfg.Model,
+		maxSize:  maxSize,
+	}
+	if maxSize > 0 {
+		files, err := s.files()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			s.size += f.size
+		}
+	}
+	return s, nil
+}
+
+// key identifies a conversation with the store's model by a hash.
+func (s *replyStore) key(messages []message) string {
+	h := sha256.New()
+	for _, part := range []string{s.provider, s.model} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, m := range messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *replyStore) path(messages []message) string {
+	return filepath.Join(s.dir, s.key(messages)+".json")
+}
+
+// load reads the stored reply to a conversation and marks it as recently
+// used. It reports false if there is none.
+func (s *replyStore) load(messages []message) (completion, bool, error) {
+	path := s.path(messages)
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is a hash in the configured reply directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return completion{}, false, nil
+	}
+	if err != nil {
+		return completion{}, false, fmt.Errorf("failed to read stored reply: %w", err)
+	}
+	var r storedReply
+	if err := json.Unmarshal(data, &r); err != nil {
+		return completion{}, false, fmt.Errorf("failed to parse stored reply %s: %w", path, err)
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now) //nolint:errcheck // Only affects the eviction order
+	return completion{
+		Content:   r.Reply,
+		Truncated: r.Truncated,
+		Usage: story.Usage{
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			ReasoningTokens:  r.ReasoningTokens,
+		},
+	}, true, nil
+}
+
+// save stores the reply to a conversation atomically (temp file + rename),
+// and evicts old replies if the store grew too large.
+func (s *replyStore) save(messages []message, reply completion) error {
+	data, err := json.MarshalIndent(storedReply{
+		Provider:         s.provider,
+		Model:            s.model,
+		Reply:            reply.Content,
+		Truncated:        reply.Truncated,
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		ReasoningTokens:  reply.Usage.ReasoningTokens,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode reply: %w", err)
+	}
+	data = append(data, '\n')
+	path := s.path(messages)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write reply file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath) //nolint:errcheck // Best effort cleanup in error path
+		return fmt.Errorf("failed to write reply file: %w", err)
+	}
+	s.size += int64(len(data)) - replaced
+
+	if s.maxSize > 0 && s.size > s.maxSize {
+		return s.evict(int64(float64(s.maxSize) * cacheEvictRatio))
+	}
+	return nil
+}
+
+// evict removes the least recently used replies until the store is no
+// larger than target.
+func (s *replyStore) evict(target int64) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(files, func(a, b replyFile) int {
+		return cmp.Or(a.modTime.Compare(b.modTime), strings.Compare(a.path, b.path))
+	})
+
+	s.size = 0
+	for _, f := range files {
+		s.size += f.size
+	}
+	for _, f := range files {
+		if s.size <= target {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to evict reply file: %w", err)
+		}
+		s.size -= f.size
+	}
+	return nil
+}
+
+type replyFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the stored replies.
+func (s *replyStore) files() ([]replyFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply directory: %w", err)
+	}
+
+	var files []replyFile
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed concurrently
+		}
+		files = append(files, replyFile{
+			path:    filepath.Join(s.dir, e.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// How a storingCompleter uses its replyStore.
+type storeMode int
+
+const (
+	// storeRecord calls the model and stores every reply; failing to store
+	// one fails the call
+	storeRecord storeMode = iota
+	// storeReplay answers with stored replies and their recorded usage,
+	// without calling the model
+	storeReplay
+	// storeCache answers with stored replies free of charge, and calls the
+	// model and stores the reply for the others
+	storeCache
+	// storeRefresh is storeCache, but always calls the model
+	storeRefresh
+)
+
+// storingCompleter is a completer that stores the raw replies of another
+// one, and answers with them depending on its mode.
+type storingCompleter struct {
+	// next calls the model; nil for storeReplay
+	next    completer
+	store   *replyStore
+	mode    storeMode
+	limiter *rateLimiter
+	log     *slog.Logger
+}
+
+// complete answers with the stored reply, or calls the model. The model is
+// called with its own timeout after waiting for the rate limiter, so that
+// stored replies neither wait nor count against the limits; ctx is unused.
+func (c *storingCompleter) complete(_ context.Context, messages []message) (completion, error) {
+	if c.mode == storeReplay || c.mode == storeCache {
+		reply, ok, err := c.store.load(messages)
+		switch {
+		case err != nil && c.mode == storeReplay:
+			return completion{}, err
+		case err != nil:
+			c.log.Warn("ignoring corrupt cached LLM reply", "error", err)
+		case ok && c.mode == storeReplay:
+			return reply, nil
+		case ok:
+			c.log.Debug("using cached LLM reply", "key", c.store.key(messages))
+			reply.Usage = story.Usage{}
+			return reply, nil
+		case c.mode == storeReplay:
+			return completion{}, fmt.Errorf("%w for conversation %s in %s", ErrNotRecorded, c.store.key(messages), c.store.dir)
+		}
+	}
+
+	reply, err := completeWithTimeout(c.next, c.limiter, messages)
+	if err != nil {
+		return reply, err
+	}
+	if err := c.store.save(messages, reply); err != nil {
+		if c.mode == storeRecord {
+			return reply, err
+		}
+		c.log.Warn("failed to cache LLM reply", "error", err)
+	}
+	return reply, nil
+}
+
+// storingExtractor extracts stories like the provider's extractor, but
+// through a storingCompleter.
+type storingExtractor struct {
+	completer *storingCompleter
+	opts      extractOptions
+}
+
+// newStoringExtractor creates the extractor for next, storing its replies
+// in store. The rate limiter of opts is moved to the completer.
+func newStoringExtractor(next completer, store *replyStore, mode storeMode, opts extractOptions, log *slog.Logger) *storingExtractor {
+	c := &storingCompleter{next: next, store: store, mode: mode, limiter: opts.limiter, log: log}
+	opts.limiter = nil
+	return &storingExtractor{completer: c, opts: opts}
+}
+
+// newProviderCompleter creates the provider's extractor as a completer,
+// with the configuration and options it uses.
+func newProviderCompleter(cfg *config.LLM) (completer, config.LLM, extractOptions, error) {
+	p, err := configuredProvider(cfg)
+	if err != nil {
+		return nil, config.LLM{}, extractOptions{}, err
+	}
+	withDefaults := p.withDefaults(cfg)
+	opts, err := loadExtractOptions(&withDefaults)
+	if err != nil {
+		return nil, config.LLM{}, extractOptions{}, err
+	}
+	next, ok := p.newExtractor(&withDefaults, opts).(completer)
+	if !ok {
+		return nil, config.LLM{}, extractOptions{}, fmt.Errorf("provider %s cannot store replies", cfg.Provider)
+	}
+	return next, withDefaults, opts, nil
+}
+
+// Extract extracts the stories of an email.
+func (e *storingExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	stories, _, err := e.ExtractWithUsage(emailData)
+	return stories, err
+}
+
+// ExtractWithUsage is like Extract, and also returns the tokens used.
+func (e *storingExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	return extract(e.completer, e.opts, emailData)
+}
+
+// PromptVersion returns the version of the prompt used for an email.
+func (e *storingExtractor) PromptVersion(emailData *email.Email) string {
+	return e.opts.prompts.forEmail(emailData).version
+}
+
+// Estimate returns the usage estimate of the provider's extractor. Stored
+// replies are not taken into account.
+func (e *storingExtractor) Estimate(emailData *email.Email) story.Estimate {
+	if est, ok := e.completer.next.(story.Estimator); ok {
+		return est.Estimate(emailData)
+	}
+	return story.Estimate{}
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/story"
+)
+
+// RetryingExtractor retries another extractor on temporary failures, such as
+// rate limits (429), server errors (5xx) and network errors, with jittered
+// exponential backoff. A Retry-After delay requested by the API is honoured.
+// Permanent failures like invalid API keys or unknown models are returned
+// right away.
+type RetryingExtractor struct {
+	next story.Extractor
+	cfg  config.Retry
+	log  *slog.Logger
+	// sleep and now are replaced in tests
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+// NewRetryingExtractor wraps next with the retry policy from cfg.
+func NewRetryingExtractor(next story.Extractor, cfg config.Retry, log *slog.Logger) *RetryingExtractor {
+	return &RetryingExtractor{
+		next:  next,
+		cfg:   cfg,
+		log:   log,
+		sleep: time.Sleep,
+		now:   time.Now,
+	}
+}
+
+// Extract calls the wrapped extractor until it succeeds, fails permanently,
+// or the attempts or time configured are used up.
+func (r *RetryingExtractor) Extract(emailData *email.Email) ([]story.Story, error) {
+	stories, _, err := r.ExtractWithUsage(emailData)
+	return stories, err
+}
+
+// ExtractWithUsage is like Extract, and also returns the tokens used by all
+// attempts.
+func (r *RetryingExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	start := r.now()
+	var usage story.Usage
+	for attempt := 1; ; attempt++ {
+		stories, attemptUsage, err := story.ExtractWithUsage(r.next, emailData)
+		usage = usage.Add(attemptUsage)
+		if err == nil || !isRetryable(err) || attempt >= r.cfg.MaxAttempts {
+			return stories, usage, err
+		}
+
+		delay := r.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		if r.cfg.MaxElapsed > 0 && r.now().Add(delay).Sub(start) > r.cfg.MaxElapsed {
+			return stories, usage, err
+		}
+
+		r.log.Warn("LLM call failed, retrying",
+			"message_id", emailData.MessageID,
+			"attempt", attempt,
+			"max_attempts", r.cfg.MaxAttempts,
+			"delay", delay,
+			"error", err)
+		r.sleep(delay)
+	}
+}
+
+// PromptVersion returns the prompt version of the wrapped extractor, if it
+// reports one.
+func (r *RetryingExtractor) PromptVersion(emailData *email.Email) string {
+	if v, ok := r.next.(story.PromptVersioner); ok {
+		return v.PromptVersion(emailData)
+	}
+	return ""
+}
+
+// Estimate returns the usage estimate of the wrapped extractor, if it
+// provides one. Retries are not included.
+func (r *RetryingExtractor) Estimate(emailData *email.Email) story.Estimate {
+	if e, ok := r.next.(story.Estimator); ok {
+		return e.Estimate(emailData)
+	}
+	return story.Estimate{}
+}
+
+// backoff returns the delay before the given retry: the initial backoff
+// doubled per attempt and capped at the maximum, of which the upper half is
+// randomized so that parallel clients don't retry in lockstep.
+func (r *RetryingExtractor) backoff(attempt int) time.Duration {
+	d := r.cfg.InitialBackoff
+	for i := 1; i < attempt && (r.cfg.MaxBackoff <= 0 || d < r.cfg.MaxBackoff); i++ {
+		d *= 2
+	}
+	if r.cfg.MaxBackoff > 0 {
+		d = min(d, r.cfg.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1) //nolint:gosec // G404: Jitter doesn't need a cryptographic RNG
+}
+
+// isRetryable reports whether an extraction error is temporary.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// The model did not answer within requestTimeout
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
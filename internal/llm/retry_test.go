@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+	"github.com/fxnn/news/internal/logger"
+	"github.com/fxnn/news/internal/story"
+)
+
+// flakyExtractor fails with the given errors in order, then succeeds.
+type flakyExtractor struct {
+	errs  []error
+	calls int
+}
+
+func (e *flakyExtractor) Extract(*email.Email) ([]story.Story, error) {
+	e.calls++
+	if len(e.errs) > 0 {
+		err := e.errs[0]
+		e.errs = e.errs[1:]
+		return nil, err
+	}
+	return []story.Story{{Headline: "Done"}}, nil
+}
+
+func newTestRetryingExtractor(next story.Extractor, cfg config.Retry) (*RetryingExtractor, *[]time.Duration) {
+	var delays []time.Duration
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRetryingExtractor(next, cfg, logger.New(false))
+	r.now = func() time.Time { return now }
+	r.sleep = func(d time.Duration) {
+		delays = append(delays, d)
+		now = now.Add(d)
+	}
+	return r, &delays
+}
+
+var testRetryConfig = config.Retry{
+	MaxAttempts:    4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	MaxElapsed:     10 * time.Minute,
+}
+
+func TestRetryingExtractor_RetriesTemporaryErrors(t *testing.T) {
+	next := &flakyExtractor{errs: []error{
+		fmt.Errorf("failed to call OpenAI API: %w", &APIError{StatusCode: http.StatusTooManyRequests}),
+		fmt.Errorf("failed to call OpenAI API: %w", &APIError{StatusCode: http.StatusBadGateway}),
+	}}
+	r, delays := newTestRetryingExtractor(next, testRetryConfig)
+
+	stories, err := r.Extract(&email.Email{})
+	if err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if len(stories) != 1 || next.calls != 3 {
+		t.Errorf("Extract() = %d stories after %d calls, want 1 after 3", len(stories), next.calls)
+	}
+
+	// Jittered backoff: 0.5-1s, then 1-2s
+	if len(*delays) != 2 {
+		t.Fatalf("delays = %v, want 2", *delays)
+	}
+	if d := (*delays)[0]; d < 500*time.Millisecond || d > time.Second {
+		t.Errorf("first delay = %v, want within [0.5s, 1s]", d)
+	}
+	if d := (*delays)[1]; d < time.Second || d > 2*time.Second {
+		t.Errorf("second delay = %v, want within [1s, 2s]", d)
+	}
+}
+
+func TestRetryingExtractor_HonoursRetryAfter(t *testing.T) {
+	next := &flakyExtractor{errs: []error{&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}}}
+	r, delays := newTestRetryingExtractor(next, testRetryConfig)
+
+	if _, err := r.Extract(&email.Email{}); err != nil {
+		t.Fatalf("Extract() unexpected error: %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 30*time.Second {
+		t.Errorf("delays = %v, want [30s] from Retry-After", *delays)
+	}
+}
+
+func TestRetryingExtractor_DoesNotRetryPermanentErrors(t *testing.T) {
+	for _, err := range []error{
+		&APIError{StatusCode: http.StatusUnauthorized, Message: "invalid api key"},
+		&APIError{StatusCode: http.StatusNotFound, Message: "model not found"},
+		errors.New("failed to parse LLM response"),
+	} {
+		next := &flakyExtractor{errs: []error{err}}
+		r, _ := newTestRetryingExtractor(next, testRetryConfig)
+
+		if _, got := r.Extract(&email.Email{}); !errors.Is(got, err) {
+			t.Errorf("Extract() error = %v, want %v", got, err)
+		}
+		if next.calls != 1 {
+			t.Errorf("%v: %d calls, want 1", err, next.calls)
+		}
+	}
+}
+
+func TestRetryingExtractor_CapsAttempts(t *testing.T) {
+	serverError := &APIError{StatusCode: http.StatusServiceUnavailable}
+	next := &flakyExtractor{errs: []error{serverError, serverError, serverError, serverError, serverError}}
+	r, _ := newTestRetryingExtractor(next, testRetryConfig)
+
+	if _, err := r.Extract(&email.Email{}); !errors.Is(err, serverError) {
+		t.Errorf("Extract() error = %v, want the last server error", err)
+	}
+	if next.calls != testRetryConfig.MaxAttempts {
+		t.Errorf("calls = %d, want %d", next.calls, testRetryConfig.MaxAttempts)
+	}
+}
+
+func TestRetryingExtractor_CapsElapsedTime(t *testing.T) {
+	next := &flakyExtractor{errs: []error{&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}}}
+	r, delays := newTestRetryingExtractor(next, testRetryConfig)
+
+	if _, err := r.Extract(&email.Email{}); err == nil {
+		t.Error("Extract() should give up when Retry-After exceeds max_elapsed")
+	}
+	if len(*delays) != 0 {
+		t.Errorf("delays = %v, want no waiting", *delays)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Retry-After", tt.value)
+		if got := parseRetryAfter(header); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got := parseRetryAfter(header); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(date) = %v, want up to 1m", got)
+	}
+}
+
+// usageExtractor is a flakyExtractor that reports 100 prompt tokens per call.
+type usageExtractor struct {
+	flakyExtractor
+}
+
+func (e *usageExtractor) ExtractWithUsage(emailData *email.Email) ([]story.Story, story.Usage, error) {
+	stories, err := e.Extract(emailData)
+	return stories, story.Usage{PromptTokens: 100}, err
+}
+
+func TestRetryingExtractor_SumsUsageOfAttempts(t *testing.T) {
+	next := &usageExtractor{flakyExtractor{errs: []error{&APIError{StatusCode: http.StatusServiceUnavailable}}}}
+	r, _ := newTestRetryingExtractor(next, testRetryConfig)
+
+	_, usage, err := r.ExtractWithUsage(&email.Email{})
+	if err != nil {
+		t.Fatalf("ExtractWithUsage() unexpected error: %v", err)
+	}
+	if usage.PromptTokens != 200 {
+		t.Errorf("PromptTokens = %d, want 200 from both attempts", usage.PromptTokens)
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxnn/news/internal/story"
+)
+
+// storiesSchema is the JSON schema of the {"stories": [...]} contract that
+// every provider's reply must follow. Providers with structured output
+// support (tool use, JSON schema formats) enforce it on the model side;
+// parseStories validates replies either way.
+var storiesSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"stories": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"headline": map[string]any{"type": "string", "description": "Short story headline, 5-8 words"},
+					"teaser":   map[string]any{"type": "string", "description": "Content type label followed by a teaser"},
+					"url":      map[string]any{"type": "string", "description": "URL of the story, copied from the email"},
+					"content_type": map[string]any{
+						"type":        "string",
+						"enum":        story.ContentTypes,
+						"description": "Type of the linked content, matching the teaser's label",
+					},
+					"tags": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Topics of the story, from the vocabulary in the prompt",
+					},
+				},
+				"required":             []string{"headline", "teaser", "url", "content_type", "tags"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"stories"},
+	"additionalProperties": false,
+}
+
+// storiesSchemaJSON is storiesSchema, encoded once for the OpenAI client.
+var storiesSchemaJSON = mustMarshal(storiesSchema)
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode JSON: %v", err))
+	}
+	return data
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/fxnn/news/internal/config"
+)
+
+// maxTags caps the tags kept per story.
+const maxTags = 3
+
+// tagVocabulary constrains the topic tags of stories to the configured
+// vocabulary, unless new tags are allowed.
+type tagVocabulary struct {
+	// tags are the normalized vocabulary, in configured order
+	tags     []string
+	known    map[string]bool
+	allowNew bool
+}
+
+func newTagVocabulary(cfg config.Tags) tagVocabulary {
+	v := tagVocabulary{known: make(map[string]bool, len(cfg.Vocabulary)), allowNew: cfg.AllowNew}
+	for _, tag := range cfg.Vocabulary {
+		tag = normalizeTag(tag)
+		if tag == "" || v.known[tag] {
+			continue
+		}
+		v.known[tag] = true
+		v.tags = append(v.tags, tag)
+	}
+	return v
+}
+
+// filter normalizes the tags the model assigned to a story, and drops
+// duplicates and tags outside of the vocabulary.
+func (v tagVocabulary) filter(tags []string) []string {
+	var kept []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] || (!v.known[tag] && !v.allowNew) {
+			continue
+		}
+		seen[tag] = true
+		kept = append(kept, tag)
+		if len(kept) == maxTags {
+			break
+		}
+	}
+	return kept
+}
+
+// normalizeTag lowercases a tag and joins its words with hyphens, so that
+// "Machine Learning" becomes machine-learning.
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
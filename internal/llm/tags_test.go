@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fxnn/news/internal/config"
+	"github.com/fxnn/news/internal/email"
+)
+
+func TestTagVocabulary_Filter(t *testing.T) {
+	tests := []struct {
+		name string
+		tags config.Tags
+		in   []string
+		want []string
+	}{
+		{"drops unknown tags", config.Tags{Vocabulary: []string{"ai", "security"}}, []string{"AI", "crypto", " security "}, []string{"ai", "security"}},
+		{"keeps new tags if allowed", config.Tags{Vocabulary: []string{"ai"}, AllowNew: true}, []string{"ai", "Machine  Learning"}, []string{"ai", "machine-learning"}},
+		{"drops duplicates and empty tags", config.Tags{AllowNew: true}, []string{"go", "Go", ""}, []string{"go"}},
+		{"keeps at most three", config.Tags{AllowNew: true}, []string{"a", "b", "c", "d"}, []string{"a", "b", "c"}},
+		{"drops all without vocabulary", config.Tags{}, []string{"ai"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTagVocabulary(tt.tags).filter(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("filter(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract_ConstrainsTags(t *testing.T) {
+	c := &scriptedCompleter{replies: []completion{
+		{Content: `{"stories":[
+			{"headline":"One","teaser":"T","url":"https://example.com/1","tags":["AI","gardening"]},
+			{"headline":"Two","teaser":"T","url":"https://example.com/2","tags":"security, ai"}
+		]}`},
+	}}
+	opts := extractOptions{prompts: builtinPromptSet(PromptDefault), tags: newTagVocabulary(config.Tags{Vocabulary: []string{"ai", "security"}})}
+
+	stories, _, err := extract(c, opts, &email.Email{Subject: "S", Body: "B"})
+	if err != nil {
+		t.Fatalf("extract() unexpected error: %v", err)
+	}
+	if len(stories) != 2 || !slices.Equal(stories[0].Tags, []string{"ai"}) || !slices.Equal(stories[1].Tags, []string{"security", "ai"}) {
+		t.Errorf("extract() = %+v, want tags constrained to the vocabulary", stories)
+	}
+}
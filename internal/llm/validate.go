@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/fxnn/news/internal/story"
+)
+
+// errNoStories is returned when a reply does not contain a stories array.
+var errNoStories = errors.New("LLM response has no stories array")
+
+// parseStories decodes a model reply into validated stories. Besides the
+// {"stories": [...]} contract it tolerates the deviations models without
+// schema enforcement commonly produce: Markdown code fences, a bare array,
+// and the array wrapped in extra keys like {"data": {"stories": [...]}}.
+func parseStories(content string) ([]story.ExtractedStory, error) {
+	var reply any
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+
+	items, err := findStories(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateStories(toExtracted(items)), nil
+}
+
+// salvageStories recovers the complete stories from the start of a reply
+// that is cut off or broken further on, like
+// {"stories": [{...}, {...}, {"headline": "Unfini
+func salvageStories(content string) []story.ExtractedStory {
+	s := stripCodeFence(content)
+	start := max(strings.Index(s, `"stories"`), 0)
+	open := strings.IndexByte(s[start:], '[')
+	if open < 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(s[start+open:]))
+	if _, err := dec.Token(); err != nil {
+		return nil
+	}
+	var items []any
+	for dec.More() {
+		var item any
+		if err := dec.Decode(&item); err != nil {
+			break
+		}
+		items = append(items, item)
+	}
+
+	return validateStories(toExtracted(items))
+}
+
+func toExtracted(items []any) []story.ExtractedStory {
+	extracted := make([]story.ExtractedStory, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		extracted = append(extracted, story.ExtractedStory{
+			Headline:    stringField(fields, "headline"),
+			Teaser:      stringField(fields, "teaser"),
+			ContentType: stringField(fields, "content_type"),
+			URL:         stringField(fields, "url"),
+			Tags:        stringsField(fields, "tags"),
+		})
+	}
+	return extracted
+}
+
+// stripCodeFence removes a surrounding ```json ... ``` block.
+func stripCodeFence(content string) string {
+	s := strings.TrimSpace(content)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		// Drop the language tag
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
+
+// findStories locates the stories array in a decoded reply. Objects without
+// a "stories" key are only descended into if they have a single key, so that
+// arbitrary objects are not mistaken for wrappers.
+func findStories(v any) ([]any, error) {
+	switch v := v.(type) {
+	case []any:
+		return v, nil
+	case map[string]any:
+		if stories, ok := v["stories"]; ok {
+			if stories == nil {
+				return nil, nil
+			}
+			items, ok := stories.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: stories is %T", errNoStories, stories)
+			}
+			return items, nil
+		}
+		if len(v) == 1 {
+			for _, inner := range v {
+				return findStories(inner)
+			}
+		}
+	}
+	return nil, errNoStories
+}
+
+func stringField(fields map[string]any, key string) string {
+	s, _ := fields[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// stringsField returns a list of strings, also accepting a single string of
+// comma-separated values as some models reply. The strings are trimmed, and
+// empty ones dropped.
+func stringsField(fields map[string]any, key string) []string {
+	var items []string
+	switch v := fields[key].(type) {
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+	case string:
+		items = strings.Split(v, ",")
+	}
+
+	var ss []string
+	for _, s := range items {
+		if s = strings.TrimSpace(s); s != "" {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// validateStories drops stories that cannot be used and repairs those that
+// can: stories without headline or with a URL that is not http(s) are
+// dropped, scheme-less URLs get https://, and only the first story per URL
+// is kept.
+func validateStories(stories []story.ExtractedStory) []story.ExtractedStory {
+	seen := make(map[string]bool, len(stories))
+	valid := make([]story.ExtractedStory, 0, len(stories))
+	for _, s := range stories {
+		if s.Headline == "" {
+			continue
+		}
+		u, ok := repairURL(s.URL)
+		if !ok || seen[u] {
+			continue
+		}
+		seen[u] = true
+		s.URL = u
+		valid = append(valid, s)
+	}
+	return valid
+}
+
+// repairURL returns raw as an absolute http(s) URL, adding https:// to
+// scheme-less URLs like "example.com/post". It reports false for anything
+// else, such as relative paths, mailto: links or prose.
+func repairURL(raw string) (string, bool) {
+	s := strings.TrimSuffix(strings.TrimPrefix(raw, "<"), ">")
+	if s == "" || strings.ContainsAny(s, " \t\n") {
+		return "", false
+	}
+
+	switch {
+	case strings.HasPrefix(s, "//"):
+		s = "https:" + s
+	case !strings.Contains(s, "://"):
+		host, _, _ := strings.Cut(s, "/")
+		if !strings.Contains(host, ".") || strings.Contains(host, ":") {
+			return "", false
+		}
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	return s, true
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fxnn/news/internal/story"
+)
+
+func TestParseStories_AcceptsContract(t *testing.T) {
+	stories, err := parseStories(`{"stories":[{"headline":" Go 1.25 ","teaser":"News. Released.","url":"https://go.dev/blog","tags":["programming"]}]}`)
+	if err != nil {
+		t.Fatalf("parseStories() unexpected error: %v", err)
+	}
+	want := story.ExtractedStory{Headline: "Go 1.25", Teaser: "News. Released.", URL: "https://go.dev/blog", Tags: []string{"programming"}}
+	if len(stories) != 1 || !reflect.DeepEqual(stories[0], want) {
+		t.Errorf("parseStories() = %+v, want [%+v]", stories, want)
+	}
+}
+
+func TestParseStories_ToleratesDeviations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"bare array", `[{"headline":"H","teaser":"T","url":"https://example.com"}]`},
+		{"wrapper key", `{"data":{"stories":[{"headline":"H","teaser":"T","url":"https://example.com"}]}}`},
+		{"wrapped array", `{"result":[{"headline":"H","teaser":"T","url":"https://example.com"}]}`},
+		{"code fence", "```json\n{\"stories\":[{\"headline\":\"H\",\"teaser\":\"T\",\"url\":\"https://example.com\"}]}\n```"},
+		{"extra fields", `{"stories":[{"headline":"H","teaser":"T","url":"https://example.com","score":5}],"note":"x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stories, err := parseStories(tt.content)
+			if err != nil {
+				t.Fatalf("parseStories() unexpected error: %v", err)
+			}
+			if len(stories) != 1 || stories[0].URL != "https://example.com" {
+				t.Errorf("parseStories() = %+v, want one story", stories)
+			}
+		})
+	}
+}
+
+func TestParseStories_TrimsTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags string
+	}{
+		{"comma-separated", `"programming, go,,  "`},
+		{"array", `[" programming", "go ", "", "  "]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stories, err := parseStories(`{"stories":[{"headline":"H","url":"https://example.com","tags":` + tt.tags + `}]}`)
+			if err != nil {
+				t.Fatalf("parseStories() unexpected error: %v", err)
+			}
+			want := []string{"programming", "go"}
+			if len(stories) != 1 || !reflect.DeepEqual(stories[0].Tags, want) {
+				t.Errorf("parseStories() = %+v, want tags %q", stories, want)
+			}
+		})
+	}
+}
+
+func TestParseStories_RejectsRepliesWithoutStories(t *testing.T) {
+	for _, content := range []string{`{"headline":"H","url":"https://example.com"}`, `{"stories":"none"}`, `"stories"`} {
+		if _, err := parseStories(content); !errors.Is(err, errNoStories) {
+			t.Errorf("parseStories(%s) error = %v, want errNoStories", content, err)
+		}
+	}
+	if _, err := parseStories(`{"stories":[`); err == nil {
+		t.Error("parseStories() should fail on invalid JSON")
+	}
+}
+
+func TestParseStories_NullStoriesIsEmpty(t *testing.T) {
+	stories, err := parseStories(`{"stories":null}`)
+	if err != nil || len(stories) != 0 {
+		t.Errorf("parseStories() = %v, %v, want no stories", stories, err)
+	}
+}
+
+func TestValidateStories(t *testing.T) {
+	stories := validateStories([]story.ExtractedStory{
+		{Headline: "Kept", URL: "https://example.com/a"},
+		{Headline: "", URL: "https://example.com/no-headline"},
+		{Headline: "Scheme-less", URL: "example.com/b"},
+		{Headline: "Protocol-relative", URL: "//example.com/c"},
+		{Headline: "Duplicate", URL: "https://example.com/a"},
+		{Headline: "Mailto", URL: "mailto:news@example.com"},
+		{Headline: "FTP", URL: "ftp://example.com/file"},
+		{Headline: "Relative", URL: "/articles/1"},
+		{Headline: "Prose", URL: "see the article above"},
+		{Headline: "Missing", URL: ""},
+	})
+
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	if len(stories) != len(want) {
+		t.Fatalf("validateStories() = %+v, want URLs %v", stories, want)
+	}
+	for i, u := range want {
+		if stories[i].URL != u {
+			t.Errorf("story %d URL = %s, want %s", i, stories[i].URL, u)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package story
+
+import "strings"
+
+// Content types of the linked content, as stored in Story.ContentType.
+const (
+	ContentArticle    = "article"
+	ContentBlogPost   = "blog-post"
+	ContentNews       = "news"
+	ContentPodcast    = "podcast"
+	ContentVideo      = "video"
+	ContentTalk       = "talk"
+	ContentTutorial   = "tutorial"
+	ContentPaper      = "paper"
+	ContentRepo       = "repo"
+	ContentTool       = "tool"
+	ContentSocialPost = "social-post"
+	ContentOther      = "other"
+)
+
+// ContentTypes are all content types, in the order offered to the model.
+var ContentTypes = []string{
+	ContentArticle, ContentBlogPost, ContentNews, ContentPodcast, ContentVideo, ContentTalk,
+	ContentTutorial, ContentPaper, ContentRepo, ContentTool, ContentSocialPost, ContentOther,
+}
+
+// teaserLabels maps the lowercase labels teasers start with, like
+// "GitHub Repo.", to content types. Labels equal to a content type are
+// recognized without being listed.
+var teaserLabels = map[string]string{
+	"artikel":           ContentArticle,
+	"blog":              ContentBlogPost,
+	"blog post":         ContentBlogPost,
+	"blogpost":          ContentBlogPost,
+	"blogbeitrag":       ContentBlogPost,
+	"nachricht":         ContentNews,
+	"nachrichten":       ContentNews,
+	"meldung":           ContentNews,
+	"podcast episode":   ContentPodcast,
+	"episode":           ContentPodcast,
+	"youtube video":     ContentVideo,
+	"keynote":           ContentTalk,
+	"webinar":           ContentTalk,
+	"vortrag":           ContentTalk,
+	"guide":             ContentTutorial,
+	"how-to":            ContentTutorial,
+	"anleitung":         ContentTutorial,
+	"research paper":    ContentPaper,
+	"study":             ContentPaper,
+	"studie":            ContentPaper,
+	"preprint":          ContentPaper,
+	"github repo":       ContentRepo,
+	"github repository": ContentRepo,
+	"repository":        ContentRepo,
+	"open source":       ContentRepo,
+	"app":               ContentTool,
+	"linkedin post":     ContentSocialPost,
+	"social media post": ContentSocialPost,
+	"mastodon post":     ContentSocialPost,
+	"tweet":             ContentSocialPost,
+	"thread":            ContentSocialPost,
+}
+
+// maxLabelWords bounds the words of a teaser label, so that a teaser's
+// first sentence is not mistaken for one.
+const maxLabelWords = 3
+
+// ParseContentType returns the content type named by s, ignoring case and
+// surrounding space, and reports whether it is known.
+func ParseContentType(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, t := range ContentTypes {
+		if s == t {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// ContentTypeFromTeaser derives the content type from the label a teaser
+// starts with, like "Podcast." or "GitHub Repo.". Teasers without a known
+// label are ContentOther.
+func ContentTypeFromTeaser(teaser string) string {
+	label, _, ok := strings.Cut(strings.TrimSpace(teaser), ".")
+	if !ok || len(strings.Fields(label)) > maxLabelWords {
+		return ContentOther
+	}
+	label = strings.ToLower(strings.Join(strings.Fields(label), " "))
+	if t, ok := teaserLabels[label]; ok {
+		return t
+	}
+	if t, ok := ParseContentType(strings.ReplaceAll(label, " ", "-")); ok {
+		return t
+	}
+	return ContentOther
+}
@@ -0,0 +1,39 @@
+package story
+
+import "testing"
+
+func TestContentTypeFromTeaser(t *testing.T) {
+	tests := []struct {
+		teaser string
+		want   string
+	}{
+		{"Article. A deep dive into B-trees.", ContentArticle},
+		{"Podcast. Two hosts discuss the news.", ContentPodcast},
+		{"GitHub Repo. A tool for diffing JSON.", ContentRepo},
+		{"Blog post. Lessons learned.", ContentBlogPost},
+		{"  research   PAPER. On transformers.", ContentPaper},
+		{"Video. The keynote of the conference.", ContentVideo},
+		{"Meldung. Die Bahn stellt ihren Fahrplan vor.", ContentNews},
+		{"Tool.", ContentTool},
+		{"Brief summary of the article in 1-2 sentences.", ContentOther},
+		{"Newsletter. About the newsletter itself.", ContentOther},
+		{"No label at all", ContentOther},
+		{"", ContentOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.teaser, func(t *testing.T) {
+			if got := ContentTypeFromTeaser(tt.teaser); got != tt.want {
+				t.Errorf("ContentTypeFromTeaser(%q) = %q, want %q", tt.teaser, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseContentType(t *testing.T) {
+	if got, ok := ParseContentType(" Podcast "); !ok || got != ContentPodcast {
+		t.Errorf("ParseContentType(%q) = %q, %v, want %q, true", " Podcast ", got, ok, ContentPodcast)
+	}
+	if _, ok := ParseContentType("webinar"); ok {
+		t.Error("ParseContentType(\"webinar\") is known, want unknown")
+	}
+}
@@ -1,12 +1,19 @@
 package story
 
-import "github.com/fxnn/news/internal/email"
+import (
+	"errors"
+
+	"github.com/fxnn/news/internal/email"
+)
 
 // ExtractedStory represents a story extracted by the LLM (without email metadata)
 type ExtractedStory struct {
 	Headline string
 	Teaser   string
 	URL      string
+	// ContentType is as the model replied; it may be empty or unknown
+	ContentType string
+	Tags        []string
 }
 
 // Extractor extracts stories from email content using an LLM
@@ -14,6 +21,73 @@ type Extractor interface {
 	Extract(email *email.Email) ([]Story, error)
 }
 
+// Usage counts the tokens used to extract the stories of an email.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	// ReasoningTokens is the part of CompletionTokens spent on thinking
+	ReasoningTokens int
+}
+
+// Add returns the sum of both usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// UsageExtractor is implemented by extractors that report the tokens they
+// used. The usage is reported for failed extractions, too.
+type UsageExtractor interface {
+	ExtractWithUsage(email *email.Email) ([]Story, Usage, error)
+}
+
+// ExtractWithUsage extracts the stories of an email, along with the tokens
+// used if the extractor reports them.
+func ExtractWithUsage(x Extractor, email *email.Email) ([]Story, Usage, error) {
+	if u, ok := x.(UsageExtractor); ok {
+		return u.ExtractWithUsage(email)
+	}
+	stories, err := x.Extract(email)
+	return stories, Usage{}, err
+}
+
+// PromptVersioner is implemented by extractors that can tell which prompt
+// version they use for an email, for the processing ledger.
+type PromptVersioner interface {
+	PromptVersion(email *email.Email) string
+}
+
+// Estimate is the expected LLM usage of extracting the stories of emails.
+type Estimate struct {
+	Requests     int
+	PromptTokens int
+	// MaxOutputTokens is the output token limit summed over all requests
+	MaxOutputTokens int
+}
+
+// Add returns the sum of both estimates.
+func (e Estimate) Add(other Estimate) Estimate {
+	return Estimate{
+		Requests:        e.Requests + other.Requests,
+		PromptTokens:    e.PromptTokens + other.PromptTokens,
+		MaxOutputTokens: e.MaxOutputTokens + other.MaxOutputTokens,
+	}
+}
+
+// Estimator is implemented by extractors that can estimate their usage for
+// an email without calling the model, for dry runs.
+type Estimator interface {
+	Estimate(email *email.Email) Estimate
+}
+
+// ErrIncomplete is wrapped by Extract errors when only some of an email's
+// stories could be recovered, e.g. from a truncated model reply. The
+// recovered stories are returned along with the error.
+var ErrIncomplete = errors.New("incomplete extraction")
+
 // StubExtractor is a test implementation that returns predefined stories
 type StubExtractor struct {
 	Stories []ExtractedStory
@@ -0,0 +1,45 @@
+package story
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrateDir upgrades the story files in dir to the current format: stories
+// extracted before content types existed get the content type of their
+// teaser's label. Each file is rewritten atomically (temp file + rename).
+// Files that are not stories are skipped. It returns the number of
+// migrated stories.
+func MigrateDir(dir string) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list story files: %w", err)
+	}
+
+	migrated := 0
+	for _, path := range matches {
+		// Skip the budget state and other hidden files
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		data, err := os.ReadFile(path) //nolint:gosec // G304: Path from glob in the story directory
+		if err != nil {
+			return migrated, fmt.Errorf("failed to read story file: %w", err)
+		}
+		var s Story
+		if err := json.Unmarshal(data, &s); err != nil || s.URL == "" || s.ContentType != "" {
+			continue
+		}
+
+		s.ContentType = ContentTypeFromTeaser(s.Teaser)
+		if err := writeStoryFile(path, s); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
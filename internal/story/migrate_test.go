@@ -0,0 +1,47 @@
+package story
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateDir_DerivesContentTypeFromTeaser(t *testing.T) {
+	dir := t.TempDir()
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	stories := []Story{
+		{Headline: "Old", Teaser: "Podcast. An episode.", URL: "https://example.com/1", Date: date},
+		{Headline: "New", Teaser: "Article. A text.", URL: "https://example.com/2", Date: date, ContentType: ContentVideo},
+	}
+	if err := WriteStoriesToDir(dir, "<test@example.com>", date, stories); err != nil {
+		t.Fatal(err)
+	}
+	budget := filepath.Join(dir, ".budget.json")
+	if err := os.WriteFile(budget, []byte(`{"date":"2006-01-02","tokens":10}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := MigrateDir(dir)
+	if err != nil {
+		t.Fatalf("MigrateDir() unexpected error: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("MigrateDir() = %d, want 1", migrated)
+	}
+
+	got, err := ReadStoriesFromDir(dir, "<test@example.com>", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ContentType != ContentPodcast || got[1].ContentType != ContentVideo {
+		t.Errorf("stories after migration = %+v, want podcast and the existing video", got)
+	}
+	if data, err := os.ReadFile(budget); err != nil || string(data) != `{"date":"2006-01-02","tokens":10}` { //nolint:gosec // G304: Reading test file in test directory
+		t.Errorf("budget state changed to %q (%v), want it untouched", data, err)
+	}
+
+	if migrated, err := MigrateDir(dir); err != nil || migrated != 0 {
+		t.Errorf("MigrateDir() again = %d, %v, want 0", migrated, err)
+	}
+}
@@ -0,0 +1,116 @@
+package story
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadStoriesFromDir reads the story files of the given email, in order.
+func ReadStoriesFromDir(dir, messageID string, date time.Time) ([]Story, error) {
+	files, err := storyFiles(dir, messageID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	stories := make([]Story, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f.path) //nolint:gosec // G304: Path from glob in the storydir
+		if err != nil {
+			return nil, fmt.Errorf("failed to read story file: %w", err)
+		}
+		var s Story
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("failed to parse story file %s: %w", filepath.Base(f.path), err)
+		}
+		stories = append(stories, s)
+	}
+
+	return stories, nil
+}
+
+// ReplaceStoriesInDir replaces the story files of the given email with the
+// given stories as a unit: all new files are written to temp files first,
+// then renamed over the old ones, and story files left over from a longer
+// previous extraction are removed. Failing to write a story thus leaves the
+// old stories in place, and readers see each file either old or new.
+func ReplaceStoriesInDir(dir, messageID string, date time.Time, stories []Story) error {
+	old, err := storyFiles(dir, messageID, date)
+	if err != nil {
+		return err
+	}
+
+	sanitized := sanitizeMessageID(messageID)
+	dateStr := date.Format("2006-01-02")
+	paths := make([]string, len(stories))
+	staged := make([]string, 0, len(stories))
+	for i, story := range stories {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%s_%s_%d.json", dateStr, sanitized, i+1))
+		tmpPath, err := stageStoryFile(paths[i], story)
+		if err != nil {
+			removeFiles(staged)
+			return err
+		}
+		staged = append(staged, tmpPath)
+	}
+
+	for i, tmpPath := range staged {
+		if err := os.Rename(tmpPath, paths[i]); err != nil {
+			removeFiles(staged[i:])
+			return fmt.Errorf("failed to rename temp file: %w", err)
+		}
+	}
+
+	for _, f := range old {
+		if f.index <= len(stories) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove old story file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// removeFiles removes temp files on an error path.
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		_ = os.Remove(path) //nolint:errcheck // Best effort cleanup in error path
+	}
+}
+
+type storyFile struct {
+	path  string
+	index int
+}
+
+// storyFiles returns the story files of the given email sorted by index.
+// Unlike the glob alone, it doesn't match emails whose sanitized message ID
+// merely starts with this one's, such as "abc_1" for "abc".
+func storyFiles(dir, messageID string, date time.Time) ([]storyFile, error) {
+	prefix := fmt.Sprintf("%s_%s_", date.Format("2006-01-02"), sanitizeMessageID(messageID))
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list story files: %w", err)
+	}
+
+	var files []storyFile
+	for _, path := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".json")
+		index, err := strconv.Atoi(suffix)
+		if err != nil || index < 1 {
+			continue
+		}
+		files = append(files, storyFile{path: path, index: index})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].index < files[j].index })
+
+	return files, nil
+}
@@ -0,0 +1,115 @@
+package story
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplaceStoriesInDir_RemovesLeftoverFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	messageID := "<abc@example.com>"
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	old := []Story{
+		{Headline: "One", URL: "https://example.com/1"},
+		{Headline: "Two", URL: "https://example.com/2"},
+		{Headline: "Three", URL: "https://example.com/3"},
+	}
+	if err := WriteStoriesToDir(tmpDir, messageID, date, old); err != nil {
+		t.Fatalf("WriteStoriesToDir() unexpected error: %v", err)
+	}
+	// Stories of an email whose message ID starts with the same characters
+	other := []Story{{Headline: "Other", URL: "https://example.com/other"}}
+	if err := WriteStoriesToDir(tmpDir, "<abc@example.com_1>", date, other); err != nil {
+		t.Fatalf("WriteStoriesToDir() unexpected error: %v", err)
+	}
+
+	updated := []Story{{Headline: "New", URL: "https://example.com/new"}}
+	if err := ReplaceStoriesInDir(tmpDir, messageID, date, updated); err != nil {
+		t.Fatalf("ReplaceStoriesInDir() unexpected error: %v", err)
+	}
+
+	got, err := ReadStoriesFromDir(tmpDir, messageID, date)
+	if err != nil {
+		t.Fatalf("ReadStoriesFromDir() unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Headline != "New" {
+		t.Errorf("stories = %+v, want only the new story", got)
+	}
+
+	got, err = ReadStoriesFromDir(tmpDir, "<abc@example.com_1>", date)
+	if err != nil {
+		t.Fatalf("ReadStoriesFromDir() unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Headline != "Other" {
+		t.Errorf("other email's stories = %+v, want them untouched", got)
+	}
+
+	tmpFiles, err := filepath.Glob(filepath.Join(tmpDir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpFiles) != 0 {
+		t.Errorf("temp files left behind: %v", tmpFiles)
+	}
+}
+
+func TestReplaceStoriesInDir_KeepsOldStoriesOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	messageID := "<abc@example.com>"
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	old := []Story{
+		{Headline: "One", URL: "https://example.com/1"},
+		{Headline: "Two", URL: "https://example.com/2"},
+	}
+	if err := WriteStoriesToDir(tmpDir, messageID, date, old); err != nil {
+		t.Fatalf("WriteStoriesToDir() unexpected error: %v", err)
+	}
+	// A directory in place of the second story's temp file fails its write
+	if err := os.Mkdir(filepath.Join(tmpDir, "2006-01-02_abc@example.com_2.json.tmp"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := []Story{
+		{Headline: "New one", URL: "https://example.com/new1"},
+		{Headline: "New two", URL: "https://example.com/new2"},
+	}
+	if err := ReplaceStoriesInDir(tmpDir, messageID, date, updated); err == nil {
+		t.Fatal("ReplaceStoriesInDir() succeeded, want an error")
+	}
+
+	got, err := ReadStoriesFromDir(tmpDir, messageID, date)
+	if err != nil {
+		t.Fatalf("ReadStoriesFromDir() unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Headline != "One" || got[1].Headline != "Two" {
+		t.Errorf("stories = %+v, want the old stories untouched", got)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "2006-01-02_abc@example.com_1.json.tmp")); !os.IsNotExist(err) {
+		t.Errorf("staged temp file left behind (stat error: %v)", err)
+	}
+}
+
+func TestReplaceStoriesInDir_NoStoriesRemovesAll(t *testing.T) {
+	tmpDir := t.TempDir()
+	messageID := "<abc@example.com>"
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	if err := WriteStoriesToDir(tmpDir, messageID, date, []Story{{Headline: "One"}}); err != nil {
+		t.Fatalf("WriteStoriesToDir() unexpected error: %v", err)
+	}
+	if err := ReplaceStoriesInDir(tmpDir, messageID, date, nil); err != nil {
+		t.Fatalf("ReplaceStoriesInDir() unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("files left in storydir: %d, want 0", len(entries))
+	}
+}
@@ -0,0 +1,74 @@
+package story
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Sink receives the stories extracted from an email.
+type Sink interface {
+	WriteStories(messageID string, date time.Time, stories []Story) error
+}
+
+// DirSink writes each story to its own JSON file in Dir, see WriteStoriesToDir.
+type DirSink struct {
+	Dir string
+}
+
+// WriteStories writes the stories of an email to the directory.
+func (s DirSink) WriteStories(messageID string, date time.Time, stories []Story) error {
+	return WriteStoriesToDir(s.Dir, messageID, date, stories)
+}
+
+// JSONLinesSink writes each story as a line of JSON, e.g. to stdout. It is
+// safe for concurrent use.
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesSink creates a sink writing JSON Lines to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// WriteStories writes the stories of an email at once, so that the stories
+// of concurrently processed emails don't interleave.
+func (s *JSONLinesSink) WriteStories(_ string, _ time.Time, stories []Story) error {
+	var data []byte
+	for _, story := range stories {
+		line, err := json.Marshal(story)
+		if err != nil {
+			return fmt.Errorf("failed to marshal story: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write stories: %w", err)
+	}
+	return nil
+}
+
+// MultiSink writes stories to each of its sinks in order, stopping at the
+// first error.
+type MultiSink []Sink
+
+// WriteStories writes the stories of an email to all sinks.
+func (m MultiSink) WriteStories(messageID string, date time.Time, stories []Story) error {
+	for _, s := range m {
+		if err := s.WriteStories(messageID, date, stories); err != nil {
+			return err
+		}
+	}
+	return nil
+}
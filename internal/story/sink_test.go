@@ -0,0 +1,61 @@
+package story
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLinesSink_WritesOneStoryPerLine(t *testing.T) {
+	var out strings.Builder
+	sink := NewJSONLinesSink(&out)
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	stories := []Story{
+		{Headline: "One", Teaser: "Multi\nline", URL: "https://example.com/1"},
+		{Headline: "Two", URL: "https://example.com/2"},
+	}
+	if err := sink.WriteStories("<a@example.com>", date, stories); err != nil {
+		t.Fatalf("WriteStories() unexpected error: %v", err)
+	}
+	if err := sink.WriteStories("<b@example.com>", date, nil); err != nil {
+		t.Fatalf("WriteStories() unexpected error: %v", err)
+	}
+
+	var got []Story
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var s Story
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("line %q is not a story: %v", scanner.Text(), err)
+		}
+		got = append(got, s)
+	}
+	if len(got) != 2 || got[0].Teaser != "Multi\nline" || got[1].Headline != "Two" {
+		t.Errorf("stories = %+v, want both stories on their own line", got)
+	}
+}
+
+func TestMultiSink_WritesToAllSinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	var out strings.Builder
+	sink := MultiSink{DirSink{Dir: tmpDir}, NewJSONLinesSink(&out)}
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	if err := sink.WriteStories("<a@example.com>", date, []Story{{Headline: "One"}}); err != nil {
+		t.Fatalf("WriteStories() unexpected error: %v", err)
+	}
+
+	exists, err := StoriesExist(tmpDir, "<a@example.com>", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("story file was not written")
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("output = %q, want one line", out.String())
+	}
+}
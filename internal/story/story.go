@@ -4,11 +4,16 @@ import "time"
 
 // Story represents a news story extracted from an email newsletter.
 type Story struct {
-	Headline  string    `json:"headline"`
-	Teaser    string    `json:"teaser"`
-	URL       string    `json:"url"`
-	FromEmail string    `json:"from_email"`
-	FromName  string    `json:"from_name"`
-	Date      time.Time `json:"date"`
-	Filename  string    `json:"filename,omitempty"` // Optional: filename for debugging
+	Headline      string    `json:"headline"`
+	Teaser        string    `json:"teaser"`
+	ContentType   string    `json:"content_type,omitempty"` // Type of the linked content, one of ContentTypes
+	URL           string    `json:"url"`
+	OriginalURL   string    `json:"original_url,omitempty"` // URL as found in the email, before canonicalization
+	FromEmail     string    `json:"from_email"`
+	FromName      string    `json:"from_name"`
+	Date          time.Time `json:"date"`
+	Filename      string    `json:"filename,omitempty"`       // Optional: filename for debugging
+	URLUnverified bool      `json:"url_unverified,omitempty"` // URL was not found among the email's links
+	PromptVersion string    `json:"prompt_version,omitempty"` // Prompt template the story was extracted with, e.g. default@1a2b3c4d
+	Tags          []string  `json:"tags,omitempty"`           // Topic tags, e.g. ai or security
 }
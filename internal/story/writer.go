@@ -24,36 +24,52 @@ func WriteStoriesToDir(dir, messageID string, date time.Time, stories []Story) e
 			continue // File already exists, skip
 		}
 
-		data, err := json.MarshalIndent(story, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal story: %w", err)
-		}
-
-		// Atomic write: write to temp file, then rename
-		// This prevents partial writes and race conditions
-		tmpPath := path + ".tmp"
-
-		// Use 0600 permissions (owner read/write only) for privacy
-		// Newsletter content may contain sensitive information
-		if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
-			return fmt.Errorf("failed to write temp file: %w", err)
-		}
-
-		// Atomic rename - if this fails, another process won the race
-		if err := os.Rename(tmpPath, path); err != nil {
-			// Clean up temp file
-			_ = os.Remove(tmpPath) //nolint:errcheck // Best effort cleanup in error path
-			// Check if target file now exists (another process created it)
+		// If this fails although the file exists now, another process won the race
+		if err := writeStoryFile(path, story); err != nil {
 			if _, statErr := os.Stat(path); statErr == nil {
-				continue // File exists now, another process won the race
+				continue
 			}
-			return fmt.Errorf("failed to rename temp file: %w", err)
+			return err
 		}
 	}
 
 	return nil
 }
 
+// writeStoryFile writes a story file atomically: to a temp file, which is
+// then renamed. This prevents partial writes and race conditions.
+func writeStoryFile(path string, story Story) error {
+	tmpPath, err := stageStoryFile(path, story)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		// Clean up temp file
+		_ = os.Remove(tmpPath) //nolint:errcheck // Best effort cleanup in error path
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// stageStoryFile writes a story to a temp file next to path, and returns
+// the temp file's path, to be renamed to path.
+func stageStoryFile(path string, story Story) (string, error) {
+	data, err := json.MarshalIndent(story, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal story: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+
+	// Use 0600 permissions (owner read/write only) for privacy
+	// Newsletter content may contain sensitive information
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return tmpPath, nil
+}
+
 // sanitizeMessageID removes angle brackets and replaces filesystem-unsafe characters
 func sanitizeMessageID(messageID string) string {
 	// Remove angle brackets
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/fxnn/news/internal/story"
 )
@@ -27,6 +28,11 @@ func ReadStories(dir string) ([]story.Story, error) {
 	stories := []story.Story{}
 
 	for _, path := range matches {
+		// Skip the budget state and other hidden files, which Glob matches
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+
 		data, err := os.ReadFile(path) //nolint:gosec // G304: Paths from Glob pattern, constrained to storydir
 		if err != nil {
 			// Skip files we can't read
@@ -42,6 +48,12 @@ func ReadStories(dir string) ([]story.Story, error) {
 		// Add filename for debugging
 		s.Filename = filepath.Base(path)
 
+		// Stories extracted before content types existed have a label in
+		// their teaser; see story.MigrateDir
+		if s.ContentType == "" {
+			s.ContentType = story.ContentTypeFromTeaser(s.Teaser)
+		}
+
 		stories = append(stories, s)
 	}
 
@@ -133,9 +133,53 @@ func TestReadStories_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestReadStories_SkipsHiddenFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A hidden file that happens to parse as a story, like the budget state could
+	hiddenFile := filepath.Join(tmpDir, ".budget.json")
+	if err := os.WriteFile(hiddenFile, []byte(`{"headline":"Hidden","url":"https://example.com"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stories, err := ReadStories(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadStories() unexpected error: %v", err)
+	}
+
+	if len(stories) != 0 {
+		t.Errorf("ReadStories() returned %d stories, want 0 (hidden file skipped)", len(stories))
+	}
+}
+
 func TestReadStories_NonExistentDir(t *testing.T) {
 	_, err := ReadStories("/nonexistent/directory")
 	if err == nil {
 		t.Error("ReadStories() expected error for nonexistent directory, got nil")
 	}
 }
+
+func TestReadStories_DerivesMissingContentType(t *testing.T) {
+	tmpDir := t.TempDir()
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	stories := []story.Story{
+		{Headline: "Old", Teaser: "Podcast. An episode.", URL: "https://example.com/1", Date: date},
+		{Headline: "New", Teaser: "Article. A text.", URL: "https://example.com/2", Date: date, ContentType: story.ContentVideo},
+	}
+	if err := story.WriteStoriesToDir(tmpDir, "<test@example.com>", date, stories); err != nil {
+		t.Fatal(err)
+	}
+
+	readStories, err := ReadStories(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadStories() unexpected error: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, s := range readStories {
+		got[s.Headline] = s.ContentType
+	}
+	if got["Old"] != story.ContentPodcast || got["New"] != story.ContentVideo {
+		t.Errorf("content types = %v, want Old: podcast, New: video", got)
+	}
+}
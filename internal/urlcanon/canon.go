@@ -0,0 +1,128 @@
+package urlcanon
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// maxUnwrapDepth bounds how many layers of redirect wrapping are peeled off.
+// Some newsletters wrap tracker links in further trackers.
+const maxUnwrapDepth = 5
+
+// Rule unwraps a tracking or redirect URL into the URL it points to.
+type Rule interface {
+	// Unwrap returns the wrapped target of u, or false if the rule does not apply.
+	Unwrap(u *url.URL) (string, bool)
+}
+
+// RuleFunc adapts an ordinary function to the Rule interface.
+type RuleFunc func(u *url.URL) (string, bool)
+
+// Unwrap calls f(u).
+func (f RuleFunc) Unwrap(u *url.URL) (string, bool) {
+	return f(u)
+}
+
+// Resolver finds the final target of a redirecting URL, typically by asking
+// the server behind it.
+type Resolver interface {
+	Resolve(ctx context.Context, rawURL string) (string, error)
+}
+
+// Canonicalizer turns tracker-wrapped newsletter links into canonical article URLs.
+type Canonicalizer struct {
+	// Rules unwrap redirect URLs offline; they are tried in order.
+	Rules []Rule
+	// Resolver follows redirects of tracker URLs that no rule could unwrap.
+	// A nil Resolver keeps canonicalization fully offline.
+	Resolver Resolver
+	// IsTracker decides which URLs are handed to the Resolver. It keeps us from
+	// sending requests to every article a newsletter links to.
+	IsTracker func(u *url.URL) bool
+	// IsTrackingParam decides which query parameters are stripped.
+	IsTrackingParam func(name string) bool
+}
+
+// New returns a Canonicalizer with the default rules, tracker hosts and
+// tracking parameters. Pass a nil resolver to stay offline.
+func New(resolver Resolver) *Canonicalizer {
+	return &Canonicalizer{
+		Rules:           DefaultRules(),
+		Resolver:        resolver,
+		IsTracker:       IsTrackerURL,
+		IsTrackingParam: IsTrackingParam,
+	}
+}
+
+// Canonicalize unwraps rawURL and strips tracking parameters. URLs that cannot
+// be parsed are returned unchanged, as are URLs whose resolution fails after
+// all offline unwrapping has been applied.
+func (c *Canonicalizer) Canonicalize(ctx context.Context, rawURL string) string {
+	current := strings.TrimSpace(rawURL)
+
+	for range maxUnwrapDepth {
+		u, err := url.Parse(current)
+		if err != nil || u.Host == "" {
+			return current
+		}
+
+		if target, ok := c.unwrap(u); ok {
+			current = target
+			continue
+		}
+
+		if c.Resolver != nil && c.IsTracker != nil && c.IsTracker(u) {
+			target, err := c.Resolver.Resolve(ctx, current)
+			if err == nil && target != "" && target != current {
+				current = target
+				continue
+			}
+		}
+
+		break
+	}
+
+	return c.stripTrackingParams(current)
+}
+
+func (c *Canonicalizer) unwrap(u *url.URL) (string, bool) {
+	for _, rule := range c.Rules {
+		if target, ok := rule.Unwrap(u); ok && isWebURL(target) {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+func (c *Canonicalizer) stripTrackingParams(rawURL string) string {
+	if c.IsTrackingParam == nil {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if c.IsTrackingParam(name) {
+			query.Del(name)
+			changed = true
+		}
+	}
+	if !changed {
+		// Keep the original parameter order and encoding
+		return rawURL
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// isWebURL reports whether s is an absolute http(s) URL.
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,179 @@
+package urlcanon
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+type fakeResolver struct {
+	targets map[string]string
+	calls   []string
+}
+
+func (r *fakeResolver) Resolve(_ context.Context, rawURL string) (string, error) {
+	r.calls = append(r.calls, rawURL)
+	if target, ok := r.targets[rawURL]; ok {
+		return target, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestCanonicalize_Offline(t *testing.T) {
+	substackPayload := base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"e":"https://example.com/essay?utm_source=substack","p":123,"s":456}`))
+
+	tests := []struct {
+		name, input, want string
+	}{
+		{
+			name:  "strips utm parameters",
+			input: "https://example.com/article?id=7&utm_source=newsletter&utm_medium=email",
+			want:  "https://example.com/article?id=7",
+		},
+		{
+			name:  "strips mailchimp ids",
+			input: "https://example.com/a?mc_cid=abc&mc_eid=def",
+			want:  "https://example.com/a",
+		},
+		{
+			name:  "keeps clean URLs untouched",
+			input: "https://example.com/a?b=2&a=1",
+			want:  "https://example.com/a?b=2&a=1",
+		},
+		{
+			name:  "unwraps query target",
+			input: "https://www.google.com/url?q=https%3A%2F%2Fexample.com%2Fx&sa=D",
+			want:  "https://example.com/x",
+		},
+		{
+			name:  "unwraps substack redirect",
+			input: "https://substack.com/redirect/2/" + substackPayload + ".signature?j=abc",
+			want:  "https://example.com/essay",
+		},
+		{
+			name:  "unwraps base64 encoded URL in query",
+			input: "https://click.example.net/c?data=" + base64.URLEncoding.EncodeToString([]byte("https://example.org/post")),
+			want:  "https://example.org/post",
+		},
+		{
+			name:  "leaves share links with url parameter alone",
+			input: "https://example.com/share?text=hi&url=https%3A%2F%2Fexample.org",
+			want:  "https://example.com/share?text=hi&url=https%3A%2F%2Fexample.org",
+		},
+		{
+			name:  "leaves articles whose path merely contains a redirect word alone",
+			input: "https://example.com/outlook-2026?url=https%3A%2F%2Fexample.org",
+			want:  "https://example.com/outlook-2026?url=https%3A%2F%2Fexample.org",
+		},
+		{
+			name:  "returns unparsable input unchanged",
+			input: "not a url",
+			want:  "not a url",
+		},
+	}
+
+	c := New(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Canonicalize(context.Background(), tt.input); got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalize_ResolvesOnlyTrackerURLs(t *testing.T) {
+	resolver := &fakeResolver{targets: map[string]string{
+		"https://example.us1.list-manage.com/track/click?u=1&id=2": "https://example.com/story?utm_campaign=x",
+	}}
+	c := New(resolver)
+
+	got := c.Canonicalize(context.Background(), "https://example.us1.list-manage.com/track/click?u=1&id=2")
+	if got != "https://example.com/story" {
+		t.Errorf("Canonicalize() = %q, want resolved and stripped URL", got)
+	}
+
+	c.Canonicalize(context.Background(), "https://example.com/plain-article")
+	if len(resolver.calls) != 1 {
+		t.Errorf("resolver called for %v, want only the tracker URL", resolver.calls)
+	}
+}
+
+func TestCanonicalize_KeepsTrackerURLWhenResolutionFails(t *testing.T) {
+	c := New(&fakeResolver{})
+
+	input := "https://ct.sendgrid.net/ls/click?upn=abc"
+	if got := c.Canonicalize(context.Background(), input); got != input {
+		t.Errorf("Canonicalize() = %q, want %q", got, input)
+	}
+}
+
+func TestCanonicalize_CustomRule(t *testing.T) {
+	c := New(nil)
+	c.Rules = append(c.Rules, RuleFunc(func(u *url.URL) (string, bool) {
+		if u.Host == "go.example.com" {
+			return "https://example.com" + u.Path, true
+		}
+		return "", false
+	}))
+
+	if got := c.Canonicalize(context.Background(), "https://go.example.com/story"); got != "https://example.com/story" {
+		t.Errorf("Canonicalize() = %q, want custom rule applied", got)
+	}
+}
+
+func TestIsTrackerURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"https://example.us5.list-manage.com/track/click?u=1", true},
+		{"https://u123.ct.sendgrid.net/ls/click?upn=x", true},
+		{"https://link.mail.beehiiv.com/ss/c/abc", true},
+		{"https://substack.com/redirect/abc", true},
+		{"https://substack.com/p/some-post", false},
+		{"https://example.com/article", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsTrackerURL(u); got != tt.want {
+			t.Errorf("IsTrackerURL(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsRedirectPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/url":                       true,
+		"/track/click":               true,
+		"/ls/click":                  true,
+		"/redirect.php":              true,
+		"/r/abc":                     true,
+		"/outlook-2026":              false,
+		"/linkerd-release":           false,
+		"/tracking-pixels-explained": false,
+		"/blog/clickbait-and-you":    false,
+		"/posts/the-way-out-of-debt": false,
+	} {
+		if got := isRedirectPath(path); got != want {
+			t.Errorf("isRedirectPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestIsTrackingParam(t *testing.T) {
+	for name, want := range map[string]bool{
+		"utm_source": true, "UTM_Medium": true, "fbclid": true, "_hsenc": true,
+		"id": false, "page": false, "ref": false,
+	} {
+		if got := IsTrackingParam(name); got != want {
+			t.Errorf("IsTrackingParam(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package urlcanon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxRedirects bounds how many redirects HTTPResolver follows.
+const maxRedirects = 10
+
+// HTTPResolver resolves redirects by requesting the URL and following the
+// server's redirects. It sends HEAD requests, falling back to GET for servers
+// that reject HEAD; response bodies are never read.
+type HTTPResolver struct {
+	client *http.Client
+}
+
+// NewHTTPResolver creates a resolver whose requests time out after the given duration.
+func NewHTTPResolver(timeout time.Duration) *HTTPResolver {
+	return &HTTPResolver{
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Resolve returns the URL that rawURL finally redirects to.
+func (r *HTTPResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	target, status, err := r.request(ctx, http.MethodHead, rawURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		target, _, err = r.request(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rawURL, err)
+	}
+	return target, nil
+}
+
+func (r *HTTPResolver) request(ctx context.Context, method, rawURL string) (string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, http.NoBody)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("User-Agent", "news-story-extractor (+https://github.com/fxnn/news)")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	_ = resp.Body.Close() //nolint:errcheck // Body is never read
+
+	return resp.Request.URL.String(), resp.StatusCode, nil
+}
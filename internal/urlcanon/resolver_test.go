@@ -0,0 +1,70 @@
+package urlcanon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPResolver_FollowsRedirects(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/click":
+			http.Redirect(w, r, "/hop", http.StatusFound)
+		case "/hop":
+			http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	got, err := NewHTTPResolver(5*time.Second).Resolve(context.Background(), server.URL+"/click")
+	if err != nil {
+		t.Fatalf("Resolve() unexpected error: %v", err)
+	}
+	if got != server.URL+"/article" {
+		t.Errorf("Resolve() = %q, want %q", got, server.URL+"/article")
+	}
+	for _, m := range methods {
+		if m != http.MethodHead {
+			t.Errorf("resolver sent %s, want only HEAD requests", m)
+		}
+	}
+}
+
+func TestHTTPResolver_FallsBackToGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/click" {
+			http.Redirect(w, r, "/article", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	got, err := NewHTTPResolver(5*time.Second).Resolve(context.Background(), server.URL+"/click")
+	if err != nil {
+		t.Fatalf("Resolve() unexpected error: %v", err)
+	}
+	if got != server.URL+"/article" {
+		t.Errorf("Resolve() = %q, want %q", got, server.URL+"/article")
+	}
+}
+
+func TestHTTPResolver_ReturnsErrorForUnreachableHost(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	if _, err := NewHTTPResolver(time.Second).Resolve(context.Background(), server.URL+"/click"); err == nil {
+		t.Error("Resolve() should return an error for an unreachable host")
+	}
+}
@@ -0,0 +1,137 @@
+package urlcanon
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultRules returns the built-in offline unwrapping rules.
+func DefaultRules() []Rule {
+	return []Rule{
+		RuleFunc(unwrapQueryTarget),
+		RuleFunc(unwrapBase64Target),
+	}
+}
+
+// targetParams are query parameters in which redirect services carry their target.
+var targetParams = []string{
+	"url", "u", "q", "target", "redirect", "redirect_url", "redirect_uri",
+	"dest", "destination", "link", "to", "goto", "out",
+}
+
+// redirectSegments identify redirect endpoints by a path segment, compared
+// without file extension, like /track/click or /redirect.php.
+var redirectSegments = map[string]bool{
+	"redirect": true, "click": true, "track": true, "out": true, "away": true,
+	"link": true, "url": true, "r": true, "l": true,
+}
+
+// unwrapQueryTarget unwraps redirect endpoints that carry their target URL in
+// plain sight as a query parameter, e.g. https://www.google.com/url?q=...
+// Only endpoints that look like redirects qualify, so that ordinary pages
+// with a url parameter (share buttons, archives) are left alone.
+func unwrapQueryTarget(u *url.URL) (string, bool) {
+	if !isRedirectPath(u.Path) && !IsTrackerURL(u) {
+		return "", false
+	}
+
+	query := u.Query()
+	for _, name := range targetParams {
+		if value := query.Get(name); isWebURL(value) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// isRedirectPath reports whether a segment of urlPath is a redirect segment.
+// Whole segments are compared, so that articles like /outlook-2026 or
+// /tracking-pixels-explained don't count as redirects.
+func isRedirectPath(urlPath string) bool {
+	for _, segment := range strings.Split(strings.ToLower(urlPath), "/") {
+		if redirectSegments[strings.TrimSuffix(segment, path.Ext(segment))] {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapBase64Target unwraps trackers that base64-encode the target URL into a
+// path segment or query parameter. The payload is either the URL itself or a
+// JSON object holding it, like Substack's /redirect/2/<JWT> links whose
+// payload's "e" field is the target.
+func unwrapBase64Target(u *url.URL) (string, bool) {
+	var candidates []string
+	for _, segment := range strings.Split(u.EscapedPath(), "/") {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		candidates = append(candidates, segment)
+	}
+	for _, values := range u.Query() {
+		candidates = append(candidates, values...)
+	}
+
+	for _, candidate := range candidates {
+		// JWT-style tokens carry the payload between dots
+		for _, piece := range strings.Split(candidate, ".") {
+			if target, ok := targetFromBase64(piece); ok {
+				return target, true
+			}
+		}
+	}
+	return "", false
+}
+
+func targetFromBase64(s string) (string, bool) {
+	if len(s) < 16 {
+		// Too short to hold a URL, and likely an ordinary word
+		return "", false
+	}
+	decoded, ok := decodeBase64(s)
+	if !ok {
+		return "", false
+	}
+	return targetFromPayload(decoded)
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding.
+func decodeBase64(s string) ([]byte, bool) {
+	for _, enc := range []*base64.Encoding{
+		base64.RawURLEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.StdEncoding,
+	} {
+		if decoded, err := enc.DecodeString(s); err == nil {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+// targetFromPayload extracts a web URL from a decoded payload: either the URL
+// itself, or the first URL-valued field of a JSON object.
+func targetFromPayload(payload []byte) (string, bool) {
+	s := strings.TrimSpace(string(payload))
+	if isWebURL(s) && !strings.ContainsAny(s, " \n\t") {
+		return s, true
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", false
+	}
+	// Prefer well-known field names, then any URL-valued field
+	for _, name := range []string{"e", "url", "u", "target", "href"} {
+		if value, ok := fields[name].(string); ok && isWebURL(value) {
+			return value, true
+		}
+	}
+	for _, value := range fields {
+		if s, ok := value.(string); ok && isWebURL(s) {
+			return s, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package urlcanon
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackerHosts are click-tracking services used by newsletter platforms. Links
+// to these hosts (or their subdomains) redirect to the actual article.
+var trackerHosts = []string{
+	"list-manage.com",  // Mailchimp
+	"mailchi.mp",       // Mailchimp
+	"ct.sendgrid.net",  // SendGrid
+	"sendgrid.net",     // SendGrid
+	"mail.beehiiv.com", // Beehiiv
+	"link.mail.beehiiv.com",
+	"convertkit-mail.com",   // ConvertKit
+	"ck.page",               // ConvertKit
+	"mlsend.com",            // MailerLite
+	"hubspotlinks.com",      // HubSpot
+	"hs-sites.com",          // HubSpot
+	"rs6.net",               // Constant Contact
+	"cmail19.com",           // Campaign Monitor
+	"cmail20.com",           // Campaign Monitor
+	"createsend.com",        // Campaign Monitor
+	"mandrillapp.com",       // Mandrill
+	"mailgun.org",           // Mailgun
+	"sparkpostmail.com",     // SparkPost
+	"awstrack.me",           // Amazon SES
+	"lnkd.in",               // LinkedIn shortener
+	"t.co",                  // Twitter shortener
+	"substack.com/redirect", // Substack, matched with path prefix below
+}
+
+// IsTrackerURL reports whether u points to a known click-tracking or
+// link-shortening service.
+func IsTrackerURL(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, tracker := range trackerHosts {
+		trackerHost, trackerPath, hasPath := strings.Cut(tracker, "/")
+		if host != trackerHost && !strings.HasSuffix(host, "."+trackerHost) {
+			continue
+		}
+		if !hasPath || strings.HasPrefix(strings.TrimPrefix(u.Path, "/"), trackerPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackingParams are query parameters that identify the reader or campaign
+// rather than the content.
+var trackingParams = map[string]bool{
+	"mc_cid": true, "mc_eid": true, // Mailchimp
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "twclid": true,
+	"_hsenc": true, "_hsmi": true, "__hssc": true, "__hstc": true, "__hsfp": true, // HubSpot
+	"mkt_tok":          true,                      // Marketo
+	"ck_subscriber_id": true,                      // ConvertKit
+	"oly_enc_id":       true, "oly_anon_id": true, // Omeda
+	"vero_id": true, "vero_conv": true, // Vero
+	"sc_cid": true, "s_cid": true, // Adobe
+	"ref_src": true, "ref_url": true, // Twitter
+	"igshid": true, // Instagram
+	"yclid":  true, // Yandex
+	"_bhlid": true, // Beehiiv
+}
+
+// IsTrackingParam reports whether a query parameter only serves tracking:
+// utm_* campaign parameters and well-known per-service click identifiers.
+func IsTrackingParam(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "utm_") || trackingParams[lower]
+}